@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/charset"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/readability"
+	"github.com/kbm-ky/gator/internal/rules"
+	"github.com/kbm-ky/gator/internal/sanitize"
+	"github.com/kbm-ky/gator/internal/termtext"
+)
+
+const (
+	// articleTimeout bounds fetching one article, well below feedTimeout
+	// since a page that slow isn't worth waiting on.
+	articleTimeout   = 15 * time.Second
+	articleBatchSize = 20
+	articlePollEvery = 30 * time.Second
+)
+
+// browsePageSize is how many posts findPostAtPosition reads at a time.
+const browsePageSize = 50
+
+// runArticleWorker fetches queued full articles until the process exits,
+// apart from scraping so slow sites don't hold up feeds.
+func runArticleWorker(s *state) {
+	ticker := time.NewTicker(articlePollEvery)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		if err := fetchQueuedArticles(context.Background(), s); err != nil {
+			s.logger.Error("unable to fetch queued articles", "error", err)
+		}
+	}
+}
+
+// fetchQueuedArticles fetches up to articleBatchSize queued articles, oldest
+// first, leaving the rest for the next run.
+func fetchQueuedArticles(ctx context.Context, s *state) error {
+	posts, err := s.db.GetQueuedArticles(ctx, articleBatchSize)
+	if err != nil {
+		return fmt.Errorf("unable to get queued articles: %w", err)
+	}
+
+	for _, post := range posts {
+		fetchCtx, cancel := context.WithTimeout(ctx, articleTimeout)
+		fetchArticle(fetchCtx, s, s.logger.With("feed_id", post.FeedID), post)
+		cancel()
+	}
+
+	return nil
+}
+
+// fetchArticle downloads the page a post links to and stores its main
+// article. A failed attempt is recorded too so it isn't retried on every
+// fetch.
+func fetchArticle(ctx context.Context, s *state, logger *slog.Logger, post database.Post) {
+	article, err := extractArticle(ctx, s, post.Url)
+	if err != nil {
+		logger.Warn("unable to extract article", "post_url", post.Url, "error", err)
+	}
+
+	//Recorded even when ctx has run out, so the post leaves the queue
+	args := database.SetPostArticleParams{
+		ID:        post.ID,
+		Article:   nullString(article),
+		FetchedAt: time.Now(),
+	}
+	if err := s.db.SetPostArticle(context.Background(), args); err != nil {
+		logger.Error("unable to save article", "post_url", post.Url, "error", err)
+	}
+}
+
+// extractArticle returns the sanitized main article of the page at pageURL.
+func extractArticle(ctx context.Context, s *state, pageURL string) (string, error) {
+	resp, head, err := getPage(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("not a web page: %s", mediaType)
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), resp.Body)
+	limited := &countingReader{r: body, max: maxFeedBytes(s)}
+	decoded, err := charset.NewReader(limited, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	article, err := readability.Extract(decoded, resp.Request.URL)
+	if err != nil {
+		return "", err
+	}
+	return sanitize.HTML(article.Content), nil
+}
+
+func handlerFullArticle(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("fullarticle expects 2 arguments: feed_url on|off")
+	}
+	feedURL, value := cmd.args[0], cmd.args[1]
+
+	var enabled bool
+	switch value {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return fmt.Errorf("fullarticle expects on or off, got %s", value)
+	}
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("unable to get feed by url: %w", err)
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added %s can change whether full articles are fetched", feed.Url)
+	}
+
+	args := database.SetFeedFullArticleParams{
+		ID:               feed.ID,
+		FetchFullArticle: enabled,
+		UpdatedAt:        time.Now(),
+	}
+	if err := s.db.SetFeedFullArticle(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to set full article mode: %w", err)
+	}
+
+	if enabled {
+		fmt.Printf("full articles will be fetched for new posts of %s\n", feed.Name)
+	} else {
+		fmt.Printf("full articles will no longer be fetched for %s\n", feed.Name)
+	}
+
+	return nil
+}
+
+// findPost looks up one of the user's posts by the ID browse shows, written
+// p12, by its position in browse's unfiltered output, counting from 1, or by
+// its url.
+func findPost(s *state, user database.User, ref string) (database.Post, error) {
+	if position, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return findPostAtPosition(s, user, position)
+	}
+
+	var post database.Post
+	var err error
+	if id, ok := strings.CutPrefix(ref, "p"); ok {
+		seq, parseErr := strconv.ParseInt(id, 10, 64)
+		if parseErr != nil {
+			return database.Post{}, fmt.Errorf("unable to parse post ID: %s [%w]", ref, parseErr)
+		}
+		args := database.GetPostForUserBySeqParams{
+			UserID: user.ID,
+			Seq:    seq,
+		}
+		post, err = s.db.GetPostForUserBySeq(context.Background(), args)
+	} else {
+		args := database.GetPostForUserByUrlParams{
+			UserID: user.ID,
+			Url:    ref,
+		}
+		post, err = s.db.GetPostForUserByUrl(context.Background(), args)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Post{}, fmt.Errorf("no post %s among the feeds you follow", ref)
+	}
+	if err != nil {
+		return database.Post{}, fmt.Errorf("unable to get post: %w", err)
+	}
+	return post, nil
+}
+
+// findPostAtPosition returns the post browse would show at position,
+// skipping muted posts the way browse does.
+func findPostAtPosition(s *state, user database.User, position int64) (database.Post, error) {
+	if position < 1 {
+		return database.Post{}, fmt.Errorf("positions in browse start at 1")
+	}
+
+	userRules, err := loadRules(s, user)
+	if err != nil {
+		return database.Post{}, fmt.Errorf("unable to load rules: %w", err)
+	}
+
+	shown := int64(0)
+	for offset := int64(0); ; offset += browsePageSize {
+		args := database.GetPostsForUserParams{
+			UserID: user.ID,
+			Limit:  browsePageSize,
+			Offset: int32(offset),
+		}
+		posts, err := s.db.GetPostsForUser(context.Background(), args)
+		if err != nil {
+			return database.Post{}, fmt.Errorf("unable to get posts for user: %w", err)
+		}
+		if len(posts) == 0 {
+			return database.Post{}, fmt.Errorf("browse shows only %d posts, not %d", shown, position)
+		}
+
+		for _, post := range posts {
+			if muted, _ := rules.Evaluate(userRules, post.FeedID, post.Title.String, post.Description.String); muted {
+				continue
+			}
+			shown++
+			if shown == position {
+				return post, nil
+			}
+		}
+	}
+}
+
+// postBody is the fullest text stored for a post: its extracted article,
+// then the feed's content, then its description.
+func postBody(article, content, description sql.NullString) string {
+	switch {
+	case article.Valid:
+		return article.String
+	case content.Valid:
+		return content.String
+	default:
+		return description.String
+	}
+}
+
+// writePost renders a post for reading in the terminal.
+func writePost(w io.Writer, post database.Post, width int) {
+	fmt.Fprintf(w, "ID: p%d\n", post.Seq)
+	fmt.Fprintf(w, "Title: %s\n", termtext.Clean(post.Title.String))
+	fmt.Fprintf(w, "Url: %s\n", termtext.Clean(post.Url))
+	if post.Author.Valid {
+		fmt.Fprintf(w, "Author: %s\n", termtext.Clean(post.Author.String))
+	}
+	if !post.Article.Valid {
+		fmt.Fprintln(w, "(no full article stored, showing the feed's text)")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, termtext.Render(postBody(post.Article, post.Content, post.Description), width))
+}
+
+// handlerRead shows a post's stored article, falling back to its content or
+// description when no article was fetched.
+func handlerRead(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("read expects 1 argument: post ID, position or url")
+	}
+
+	post, err := findPost(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	writePost(os.Stdout, post, terminalWidth())
+	return nil
+}
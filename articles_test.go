@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const testArticlePage = `<!doctype html>
+<html><head><title>Crocs</title></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<article>
+<h1>Crocs love mud</h1>
+<p>Crocodiles spend their days in the mud, where it is cool and quiet and
+nobody bothers them about anything at all, which is how they like it.</p>
+<p>When the sun goes down they slide back into the water and wait, sometimes
+for hours, for something interesting to wander past the riverbank.</p>
+</article>
+<footer>Copyright gators.example</footer>
+</body></html>`
+
+func TestFetchQueuedArticles(t *testing.T) {
+	s := newTestState(t)
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testArticlePage))
+	}))
+	t.Cleanup(server.Close)
+
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", server.URL+"/feed")
+	err := s.db.SetFeedFullArticle(ctx, database.SetFeedFullArticleParams{
+		ID:               feed.ID,
+		FetchFullArticle: true,
+		UpdatedAt:        time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed.FetchFullArticle = true
+
+	//Saving only queues the article
+	for _, link := range []string{server.URL + "/crocs", server.URL + "/slow"} {
+		savePost(s, s.logger, feed, RSSItem{Title: "Crocs", Link: link})
+	}
+	queued, err := s.db.GetQueuedArticles(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 2 {
+		t.Fatalf("got %d queued articles, want 2", len(queued))
+	}
+
+	//A short deadline stands in for articleTimeout on the slow page
+	runCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := fetchQueuedArticles(runCtx, s); err != nil {
+		t.Fatal(err)
+	}
+
+	queued, err = s.db.GetQueuedArticles(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 0 {
+		t.Errorf("%d articles still queued", len(queued))
+	}
+
+	post, err := findPost(s, user, server.URL+"/crocs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !post.Article.Valid || !strings.Contains(post.Article.String, "slide back into the water") {
+		t.Errorf("article = %q", post.Article.String)
+	}
+	if strings.Contains(post.Article.String, "Copyright") {
+		t.Errorf("article kept the page footer: %q", post.Article.String)
+	}
+
+	slow, err := findPost(s, user, server.URL+"/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slow.Article.Valid || !slow.ArticleFetchedAt.Valid {
+		t.Errorf("slow article = %v, fetched at %v, want a recorded failure", slow.Article, slow.ArticleFetchedAt)
+	}
+}
+
+func TestFindPost(t *testing.T) {
+	s := newTestState(t)
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", "https://gators.example/feed")
+	older := createTestPost(t, s, feed, "https://gators.example/1", time.Now().Add(-time.Hour))
+	post := createTestPost(t, s, feed, "https://gators.example/2", time.Now())
+	other := createTestUser(t, s, "bob")
+	hidden := createTestPost(t, s, createTestFeed(t, s, other, "Bob's", "https://bob.example/feed"), "https://bob.example/1", time.Now())
+
+	for ref, want := range map[string]database.Post{
+		fmt.Sprintf("p%d", post.Seq): post,
+		post.Url:                     post,
+		"1":                          post,
+		"2":                          older,
+	} {
+		got, err := findPost(s, user, ref)
+		if err != nil {
+			t.Errorf("findPost(%q) failed: %v", ref, err)
+			continue
+		}
+		if got.ID != want.ID {
+			t.Errorf("findPost(%q) = %s, want %s", ref, got.Url, want.Url)
+		}
+	}
+
+	for _, ref := range []string{
+		"0",
+		"3",
+		"px",
+		fmt.Sprintf("p%d", hidden.Seq),
+		hidden.Url,
+	} {
+		if got, err := findPost(s, user, ref); err == nil {
+			t.Errorf("findPost(%q) = %s, want an error", ref, got.Url)
+		}
+	}
+}
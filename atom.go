@@ -0,0 +1,96 @@
+package main
+
+import (
+	"html"
+	"strings"
+)
+
+const atomNS = "http://www.w3.org/2005/Atom"
+
+// atomEntry is an Atom <entry>. It shares element names with an RSS <item>
+// but not their shapes, so it is decoded on its own and turned into one.
+type atomEntry struct {
+	Title      atomText      `xml:"title"`
+	Links      []atomLink    `xml:"link"`
+	Summary    atomText      `xml:"summary"`
+	Content    atomText      `xml:"content"`
+	Published  string        `xml:"published"`
+	Updated    string        `xml:"updated"`
+	Authors    []RSSAuthor   `xml:"author"`
+	Categories []RSSCategory `xml:"category"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// atomText is an Atom text construct, which holds plain text, escaped HTML
+// or inline XHTML depending on its type.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// html returns the text as markup, escaping plain text.
+func (t atomText) html() string {
+	switch strings.ToLower(t.Type) {
+	case "html", "text/html":
+		return strings.TrimSpace(t.Text)
+	case "xhtml", "application/xhtml+xml":
+		return strings.TrimSpace(t.Inner)
+	default:
+		return html.EscapeString(strings.TrimSpace(t.Text))
+	}
+}
+
+// plain returns the text with any markup left as is, the same way an RSS
+// title is read.
+func (t atomText) plain() string {
+	if strings.EqualFold(t.Type, "xhtml") {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(html.UnescapeString(t.Text))
+}
+
+// item converts the entry to an RSSItem so posts are saved the same way
+// whichever format they came in.
+func (entry atomEntry) item() RSSItem {
+	item := RSSItem{
+		Title:       entry.Title.plain(),
+		Description: entry.Summary.html(),
+		PubDate:     entry.Published,
+		AtomContent: entry.Content.html(),
+		Categories:  entry.Categories,
+	}
+	if item.PubDate == "" {
+		item.PubDate = entry.Updated
+	}
+	//Without a summary the content is the best description there is
+	if item.Description == "" {
+		item.Description = item.AtomContent
+	}
+	if len(entry.Authors) > 0 {
+		item.Author = entry.Authors[0]
+	}
+
+	for _, link := range entry.Links {
+		switch link.Rel {
+		case "", "alternate":
+			if item.Link == "" {
+				item.Link = strings.TrimSpace(link.Href)
+			}
+		case "enclosure":
+			item.Enclosures = append(item.Enclosures, RSSEnclosure{
+				Url:    strings.TrimSpace(link.Href),
+				Type:   link.Type,
+				Length: link.Length,
+			})
+		}
+	}
+
+	return item
+}
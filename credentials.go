@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+func handlerApiPassword(s *state, cmd command, user database.User) error {
+	//check args
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("apipassword expects 1 argument: password")
+	}
+	password := cmd.args[0]
+
+	if err := saveApiPassword(context.Background(), s, user, password); err != nil {
+		return err
+	}
+
+	fmt.Printf("api password for %s has been set\n", user.Name)
+
+	return nil
+}
+
+// saveApiPassword stores the hash of the user's api password along with
+// the Fever key derived from it.
+func saveApiPassword(ctx context.Context, s *state, user database.User, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("unable to hash password: %w", err)
+	}
+
+	now := time.Now()
+	args := database.UpsertApiCredentialsParams{
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		UserID:       user.ID,
+		PasswordHash: passwordHash,
+		FeverKey:     feverKey(user.Name, password),
+	}
+	if _, err := s.db.UpsertApiCredentials(ctx, args); err != nil {
+		return fmt.Errorf("unable to save api credentials: %w", err)
+	}
+	return nil
+}
+
+// feverKey is what Fever clients send as api_key: md5 of "email:password",
+// where gator uses the user name in place of an email address.
+func feverKey(userName, password string) string {
+	sum := md5.Sum([]byte(userName + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	// passwordScheme prefixes every password hash gator writes.
+	passwordScheme = "pbkdf2-sha256"
+	// passwordIterations is the PBKDF2 work factor.
+	passwordIterations = 600_000
+	passwordSaltLen    = 16
+	passwordKeyLen     = 32
+)
+
+// hashPassword returns "pbkdf2-sha256$iterations$salt$key" with the salt and
+// key hex encoded.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to generate salt: %w", err)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches passwordHash, as written
+// by hashPassword.
+func checkPassword(passwordHash, password string) bool {
+	fields := strings.Split(passwordHash, "$")
+	if len(fields) != 4 || fields[0] != passwordScheme {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(fields[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(fields[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1
+}
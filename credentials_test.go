@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, passwordScheme+"$600000$") {
+		t.Errorf("unexpected hash format: %s", hash)
+	}
+	if !checkPassword(hash, "hunter2") {
+		t.Error("the password doesn't match its own hash")
+	}
+	if checkPassword(hash, "hunter3") {
+		t.Error("a wrong password matched")
+	}
+
+	again, err := hashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Error("two hashes of one password should have different salts")
+	}
+}
+
+func TestCheckPasswordMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"nonsense",
+		"pbkdf2-sha256$0$00$00",
+		"pbkdf2-sha256$x$00$00",
+		"pbkdf2-sha256$1000$zz$00",
+		"pbkdf2-sha256$1000$00$",
+	} {
+		if checkPassword(hash, "") {
+			t.Errorf("checkPassword(%q) matched", hash)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/sanitize"
+)
+
+const defaultDigestFrom = "gator@localhost"
+
+var digestIntervals = map[string]time.Duration{
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// digestSlack lets a digest scheduled by cron at the same time every day go
+// out even when the previous run finished a few seconds later than this one.
+const digestSlack = time.Hour
+
+type digestGroup struct {
+	FeedName string
+	Posts    []database.GetDigestPostsForUserRow
+}
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Funcs(htmltemplate.FuncMap{
+	"sanitize": func(fragment string) htmltemplate.HTML {
+		return htmltemplate.HTML(sanitize.HTML(fragment))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<body>
+<h1>{{.Count}} new posts for {{.UserName}}</h1>
+{{range .Groups}}
+<h2>{{.FeedName}}</h2>
+<ul>
+{{range .Posts}}<li><a href="{{.Url}}">{{if .Title.Valid}}{{.Title.String}}{{else}}{{.Url}}{{end}}</a>{{if .Description.Valid}}<br>{{sanitize .Description.String}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
+
+func handlerDigest(s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("digest expects a sub-command: subscribe, unsubscribe or send")
+	}
+	sub := command{name: cmd.args[0], args: cmd.args[1:]}
+
+	switch sub.name {
+	case "subscribe":
+		return middlewareLoggedIn(digestSubscribe)(s, sub)
+	case "unsubscribe":
+		return middlewareLoggedIn(digestUnsubscribe)(s, sub)
+	case "send":
+		return digestSend(s, sub)
+	default:
+		return fmt.Errorf("unknown digest sub-command: %s", sub.name)
+	}
+}
+
+func digestSubscribe(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 || len(cmd.args) > 2 {
+		return fmt.Errorf("digest subscribe expects 1 or 2 arguments: email [daily|weekly]")
+	}
+	address, err := parseDigestAddress(cmd.args[0])
+	if err != nil {
+		return err
+	}
+	email := address.Address
+
+	frequency := "daily"
+	if len(cmd.args) == 2 {
+		frequency = cmd.args[1]
+	}
+	if _, ok := digestIntervals[frequency]; !ok {
+		return fmt.Errorf("unknown digest frequency: %s", frequency)
+	}
+
+	now := time.Now()
+	args := database.UpsertDigestParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Email:     email,
+		Frequency: frequency,
+	}
+	if _, err := s.db.UpsertDigest(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to save digest subscription: %w", err)
+	}
+
+	fmt.Printf("%s digest for %s will be sent to %s\n", frequency, user.Name, email)
+
+	return nil
+}
+
+func digestUnsubscribe(s *state, cmd command, user database.User) error {
+	if err := s.db.DeleteDigest(context.Background(), user.ID); err != nil {
+		return fmt.Errorf("unable to delete digest subscription: %w", err)
+	}
+
+	fmt.Printf("digest for %s has been turned off\n", user.Name)
+
+	return nil
+}
+
+// digestSend mails every subscriber whose digest is due the posts gathered
+// since their last one, or writes them as .eml files into a directory.
+func digestSend(s *state, cmd command) error {
+	if len(cmd.args) > 1 {
+		return fmt.Errorf("digest send expects at most 1 argument: [eml_dir]")
+	}
+	emlDir := ""
+	if len(cmd.args) == 1 {
+		emlDir = cmd.args[0]
+	}
+	if emlDir == "" && s.cfg.SMTPHost == "" {
+		return fmt.Errorf("smtp_host is not configured, pass a directory to write .eml files instead")
+	}
+
+	digests, err := s.db.GetDigests(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to get digests: %w", err)
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, digest := range digests {
+		interval := digestIntervals[digest.Frequency]
+		if digest.LastSentAt.Valid && now.Sub(digest.LastSentAt.Time) < interval-digestSlack {
+			continue
+		}
+
+		//One subscriber's failure shouldn't hold up everyone after them
+		if err := sendUserDigest(s, digest, emlDir, now); err != nil {
+			s.logger.Error("unable to send digest", "user", digest.UserName, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendUserDigest sends one subscriber the posts since their last digest and
+// records it as sent.
+func sendUserDigest(s *state, digest database.GetDigestsRow, emlDir string, now time.Time) error {
+	args := database.GetDigestPostsForUserParams{
+		UserID: digest.UserID,
+		Since:  digestSince(digest, now),
+		Until:  now,
+	}
+	posts, err := s.db.GetDigestPostsForUser(context.Background(), args)
+	if err != nil {
+		return fmt.Errorf("unable to get digest posts for %s: %w", digest.UserName, err)
+	}
+
+	if len(posts) > 0 {
+		msg, err := buildDigestMessage(digestFrom(s), digest, posts, now)
+		if err != nil {
+			return fmt.Errorf("unable to build digest for %s: %w", digest.UserName, err)
+		}
+
+		if emlDir != "" {
+			fileName := filepath.Join(emlDir, fmt.Sprintf("%s-%s.eml", digest.UserName, now.Format("20060102-150405")))
+			if err := os.WriteFile(fileName, msg, 0666); err != nil {
+				return fmt.Errorf("unable to write digest: %w", err)
+			}
+			fmt.Printf("wrote %d posts for %s to %s\n", len(posts), digest.UserName, fileName)
+		} else {
+			if err := sendDigest(s, digest.Email, msg); err != nil {
+				return fmt.Errorf("unable to send digest to %s: %w", digest.Email, err)
+			}
+			fmt.Printf("sent %d posts to %s\n", len(posts), digest.Email)
+		}
+	}
+
+	markArgs := database.MarkDigestSentParams{
+		ID:         digest.ID,
+		LastSentAt: sql.NullTime{Time: now, Valid: true},
+		UpdatedAt:  now,
+	}
+	if err := s.db.MarkDigestSent(context.Background(), markArgs); err != nil {
+		return fmt.Errorf("unable to mark digest sent: %w", err)
+	}
+
+	return nil
+}
+
+// digestSince is where a digest sent at now starts: the last one sent, or
+// one interval back for the first.
+func digestSince(digest database.GetDigestsRow, now time.Time) time.Time {
+	if digest.LastSentAt.Valid {
+		return digest.LastSentAt.Time
+	}
+	return now.Add(-digestIntervals[digest.Frequency])
+}
+
+// parseDigestAddress checks that address is a single email address, which
+// also keeps it from smuggling extra headers into the message.
+func parseDigestAddress(address string) (*mail.Address, error) {
+	if strings.ContainsAny(address, "\r\n") {
+		return nil, fmt.Errorf("email address may not contain line breaks: %q", address)
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse email address: %s [%w]", address, err)
+	}
+	return parsed, nil
+}
+
+func digestFrom(s *state) string {
+	if s.cfg.DigestFrom != "" {
+		return s.cfg.DigestFrom
+	}
+	return defaultDigestFrom
+}
+
+func sendDigest(s *state, to string, msg []byte) error {
+	port := s.cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	addr := s.cfg.SMTPHost + ":" + strconv.Itoa(port)
+
+	var auth smtp.Auth
+	if s.cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost)
+	}
+
+	from, err := parseDigestAddress(digestFrom(s))
+	if err != nil {
+		return fmt.Errorf("invalid digest_from: %w", err)
+	}
+	return smtp.SendMail(addr, auth, from.Address, []string{to}, msg)
+}
+
+// buildDigestMessage renders a multipart/alternative message with a
+// plaintext and an HTML version of the posts, grouped by feed.
+func buildDigestMessage(from string, digest database.GetDigestsRow, posts []database.GetDigestPostsForUserRow, now time.Time) ([]byte, error) {
+	//Addresses saved or configured before they were checked are checked here
+	fromAddress, err := parseDigestAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest_from: %w", err)
+	}
+	toAddress, err := parseDigestAddress(digest.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []digestGroup
+	for _, post := range posts {
+		if len(groups) == 0 || groups[len(groups)-1].FeedName != post.FeedName {
+			groups = append(groups, digestGroup{FeedName: post.FeedName})
+		}
+		groups[len(groups)-1].Posts = append(groups[len(groups)-1].Posts, post)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d new posts for %s\n\n", len(posts), digest.UserName)
+	for _, group := range groups {
+		fmt.Fprintf(&text, "== %s ==\n\n", group.FeedName)
+		for _, post := range group.Posts {
+			if post.Title.Valid {
+				fmt.Fprintf(&text, "* %s\n  %s\n\n", post.Title.String, post.Url)
+			} else {
+				fmt.Fprintf(&text, "* %s\n\n", post.Url)
+			}
+		}
+	}
+	if err := writeQuotedPrintablePart(mw, "text/plain; charset=utf-8", []byte(text.String())); err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	data := map[string]any{
+		"Count":    len(posts),
+		"UserName": digest.UserName,
+		"Groups":   groups,
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("unable to render html: %w", err)
+	}
+	if err := writeQuotedPrintablePart(mw, "text/html; charset=utf-8", html.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to close multipart writer: %w", err)
+	}
+
+	subject := fmt.Sprintf("gator %s digest: %d new posts", digest.Frequency, len(posts))
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", fromAddress)
+	fmt.Fprintf(&msg, "To: %s\r\n", toAddress)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@gator>\r\n", uuid.New())
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n", mw.Boundary())
+	fmt.Fprintf(&msg, "\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func writeQuotedPrintablePart(mw *multipart.Writer, contentType string, content []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("unable to create part: %w", err)
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write(content); err != nil {
+		return fmt.Errorf("unable to write part: %w", err)
+	}
+	return qp.Close()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/config"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+func TestParseDigestAddress(t *testing.T) {
+	for address, want := range map[string]string{
+		"ally@gators.example":                   "ally@gators.example",
+		"Ally Gator <ally@gators.example>":      "ally@gators.example",
+		" <ally@gators.example> ":               "ally@gators.example",
+		"\"Gator, Ally\" <ally@gators.example>": "ally@gators.example",
+	} {
+		got, err := parseDigestAddress(address)
+		if err != nil {
+			t.Errorf("parseDigestAddress(%q) failed: %v", address, err)
+			continue
+		}
+		if got.Address != want {
+			t.Errorf("parseDigestAddress(%q) = %q, want %q", address, got.Address, want)
+		}
+	}
+
+	for _, address := range []string{
+		"",
+		"not an address",
+		"ally@gators.example, bob@bob.example",
+		"ally@gators.example\r\nBcc: everyone@gators.example",
+		"ally@gators.example\nBcc: everyone@gators.example",
+		"Ally\r\n <ally@gators.example>",
+	} {
+		if got, err := parseDigestAddress(address); err == nil {
+			t.Errorf("parseDigestAddress(%q) = %q, want an error", address, got)
+		}
+	}
+}
+
+func TestDigestSince(t *testing.T) {
+	now := time.Date(2024, 3, 5, 7, 0, 0, 0, time.UTC)
+	lastSent := now.Add(-30 * time.Hour)
+
+	tests := []struct {
+		frequency string
+		lastSent  sql.NullTime
+		want      time.Time
+	}{
+		{"daily", sql.NullTime{}, now.Add(-24 * time.Hour)},
+		{"weekly", sql.NullTime{}, now.Add(-7 * 24 * time.Hour)},
+		{"daily", sql.NullTime{Time: lastSent, Valid: true}, lastSent},
+		{"weekly", sql.NullTime{Time: lastSent, Valid: true}, lastSent},
+	}
+	for _, tt := range tests {
+		digest := database.GetDigestsRow{Frequency: tt.frequency, LastSentAt: tt.lastSent}
+		if got := digestSince(digest, now); !got.Equal(tt.want) {
+			t.Errorf("digestSince(%s, last sent %v) = %s, want %s", tt.frequency, tt.lastSent, got, tt.want)
+		}
+	}
+}
+
+func digestPost(feedName, title, url, description string) database.GetDigestPostsForUserRow {
+	return database.GetDigestPostsForUserRow{
+		Title:       sql.NullString{String: title, Valid: title != ""},
+		Url:         url,
+		Description: sql.NullString{String: description, Valid: description != ""},
+		FeedName:    feedName,
+	}
+}
+
+// digestParts reads a digest back into its headers and its decoded parts,
+// keyed by content type, with mail's CRLF line endings turned into \n.
+func digestParts(t *testing.T, raw []byte) (mail.Header, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Errorf("Content-Transfer-Encoding = %q", got)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[part.Header.Get("Content-Type")] = strings.ReplaceAll(string(body), "\r\n", "\n")
+	}
+	return msg.Header, parts
+}
+
+func TestBuildDigestMessage(t *testing.T) {
+	now := time.Date(2024, 3, 5, 7, 0, 0, 0, time.UTC)
+	digest := database.GetDigestsRow{
+		UserName:  "alice",
+		Email:     "ally@gators.example",
+		Frequency: "daily",
+	}
+	//Posts come grouped by feed
+	posts := []database.GetDigestPostsForUserRow{
+		digestPost("Gators", "Crocs & mud", "https://gators.example/1", `<p>Mud <script>alert(1)</script>is great</p>`),
+		digestPost("Gators", "", "https://gators.example/2", ""),
+		digestPost("Swamps", "Ünïcode títle", "https://swamps.example/1", ""),
+	}
+
+	raw, err := buildDigestMessage("Gator <gator@gators.example>", digest, posts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, parts := digestParts(t, raw)
+
+	if got := header.Get("From"); got != `"Gator" <gator@gators.example>` {
+		t.Errorf("From = %q", got)
+	}
+	if got := header.Get("To"); got != "<ally@gators.example>" {
+		t.Errorf("To = %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if err != nil || subject != "gator daily digest: 3 new posts" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if date, err := header.Date(); err != nil || !date.Equal(now) {
+		t.Errorf("Date = %s, %v", date, err)
+	}
+	if header.Get("Message-ID") == "" || header.Get("MIME-Version") != "1.0" {
+		t.Errorf("Message-ID %q, MIME-Version %q", header.Get("Message-ID"), header.Get("MIME-Version"))
+	}
+
+	text := parts["text/plain; charset=utf-8"]
+	for _, want := range []string{
+		"3 new posts for alice",
+		"== Gators ==\n\n* Crocs & mud\n  https://gators.example/1\n\n* https://gators.example/2\n",
+		"== Swamps ==\n\n* Ünïcode títle\n  https://swamps.example/1\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part lacks %q:\n%s", want, text)
+		}
+	}
+
+	html := parts["text/html; charset=utf-8"]
+	for _, want := range []string{
+		"<h2>Gators</h2>",
+		`<a href="https://gators.example/1">Crocs &amp; mud</a>`,
+		"<p>Mud is great</p>",
+		`<a href="https://gators.example/2">https://gators.example/2</a>`,
+		"<h2>Swamps</h2>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html part lacks %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("html part kept a script:\n%s", html)
+	}
+	if len(parts) != 2 {
+		t.Errorf("got parts %q, want text and html", parts)
+	}
+}
+
+func TestBuildDigestMessageRejectsHeaderInjection(t *testing.T) {
+	posts := []database.GetDigestPostsForUserRow{digestPost("Gators", "One", "https://gators.example/1", "")}
+
+	digest := database.GetDigestsRow{
+		UserName:  "alice",
+		Email:     "ally@gators.example\r\nBcc: everyone@gators.example",
+		Frequency: "daily",
+	}
+	if _, err := buildDigestMessage(defaultDigestFrom, digest, posts, time.Now()); err == nil {
+		t.Error("a recipient with a line break was accepted")
+	}
+
+	digest.Email = "ally@gators.example"
+	if _, err := buildDigestMessage("gator@gators.example\r\nBcc: everyone@gators.example", digest, posts, time.Now()); err == nil {
+		t.Error("a sender with a line break was accepted")
+	}
+}
+
+func TestSendUserDigestWindow(t *testing.T) {
+	s := newTestState(t)
+	s.cfg = &config.Config{}
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", "https://gators.example/feed")
+
+	now := time.Now()
+	lastSent := now.Add(-2 * time.Hour)
+	createTestPost(t, s, feed, "https://gators.example/before", lastSent.Add(-time.Minute))
+	createTestPost(t, s, feed, "https://gators.example/after", lastSent.Add(time.Minute))
+
+	digest, err := s.db.UpsertDigest(ctx, database.UpsertDigestParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Email:     "ally@gators.example",
+		Frequency: "daily",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.db.MarkDigestSent(ctx, database.MarkDigestSentParams{
+		ID:         digest.ID,
+		LastSentAt: sql.NullTime{Time: lastSent, Valid: true},
+		UpdatedAt:  lastSent,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digests, err := s.db.GetDigests(ctx)
+	if err != nil || len(digests) != 1 {
+		t.Fatalf("GetDigests = %d digests, %v", len(digests), err)
+	}
+
+	dir := t.TempDir()
+	if err := sendUserDigest(s, digests[0], dir, now); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("wrote %q, %v", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, parts := digestParts(t, raw)
+	text := parts["text/plain; charset=utf-8"]
+	if !strings.Contains(text, "https://gators.example/after") || strings.Contains(text, "https://gators.example/before") {
+		t.Errorf("digest since the last one holds:\n%s", text)
+	}
+
+	//The next one starts where this one ended
+	digests, err = s.db.GetDigests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := digestSince(digests[0], now.Add(24*time.Hour))
+	if !digests[0].LastSentAt.Valid || next.Sub(now).Abs() > time.Millisecond {
+		t.Errorf("next digest starts at %s, want %s", next, now)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kbm-ky/gator/internal/charset"
+	"github.com/kbm-ky/gator/internal/database"
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the <link type> values that advertise a feed gator can
+// read. JSON Feeds are left out since Items only decodes RSS and Atom.
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+}
+
+// wellKnownFeedPaths are tried, relative to the site root, when a page
+// doesn't advertise any feeds.
+var wellKnownFeedPaths = []string{
+	"/feed",
+	"/rss",
+	"/feed.xml",
+	"/rss.xml",
+	"/atom.xml",
+	"/index.xml",
+}
+
+type feedCandidate struct {
+	Url   string
+	Title string
+	Type  string
+}
+
+func handlerDiscover(s *state, cmd command) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("discover expects 1 argument: url")
+	}
+
+	candidates, err := discoverFeeds(context.Background(), s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no feeds found at %s", cmd.args[0])
+	}
+
+	printCandidates(candidates)
+
+	return nil
+}
+
+// discoverFeeds returns the feeds available at pageURL. A URL that already
+// serves a feed is its own only candidate, otherwise the page's alternate
+// links are used, falling back to probing well-known paths.
+func discoverFeeds(ctx context.Context, s *state, pageURL string) ([]feedCandidate, error) {
+	resp, head, err := getPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if looksLikeFeed(resp.Header.Get("Content-Type"), head) {
+		return []feedCandidate{{Url: pageURL}}, nil
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), resp.Body)
+	limited := &countingReader{r: body, max: maxFeedBytes(s)}
+	decoded, err := charset.NewReader(limited, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := feedLinks(decoded, resp.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	//Nothing advertised, so try the usual suspects
+	for _, path := range wellKnownFeedPaths {
+		ref, _ := url.Parse(path)
+		probeURL := resp.Request.URL.ResolveReference(ref).String()
+
+		probe, probeHead, err := getPage(ctx, probeURL)
+		if err != nil {
+			s.logger.Debug("feed probe failed", "url", probeURL, "error", err)
+			continue
+		}
+		probe.Body.Close()
+
+		if looksLikeFeed(probe.Header.Get("Content-Type"), probeHead) {
+			candidates = append(candidates, feedCandidate{Url: probe.Request.URL.String()})
+		}
+	}
+
+	return candidates, nil
+}
+
+// getPage requests pageURL and peeks at the start of its body.
+func getPage(ctx context.Context, pageURL string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to make new requst: %w", err)
+	}
+	req.Header.Set("User-Agent", "gator")
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to do request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	return resp, head[:n], nil
+}
+
+// looksLikeFeed decides from the Content-Type and the first bytes of a body
+// whether it is an RSS or Atom feed rather than a web page or a feed in a
+// format Items can't decode.
+func looksLikeFeed(contentType string, head []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if feedLinkTypes[mediaType] {
+		return true
+	}
+
+	start := strings.ToLower(string(head))
+	if strings.Contains(start, "<html") {
+		return false
+	}
+	return strings.Contains(start, "<rss") || strings.Contains(start, "<feed")
+}
+
+// feedLinks collects <link rel="alternate"> feed links from an HTML page,
+// resolving them against base.
+func feedLinks(r io.Reader, base *url.URL) ([]feedCandidate, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse html: %w", err)
+	}
+
+	var candidates []feedCandidate
+	seen := map[string]bool{}
+	for node := range doc.Descendants() {
+		if node.Type != html.ElementNode || node.Data != "link" {
+			continue
+		}
+
+		attrs := map[string]string{}
+		for _, attr := range node.Attr {
+			attrs[attr.Key] = attr.Val
+		}
+
+		rels := strings.Fields(strings.ToLower(attrs["rel"]))
+		linkType := strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if !slices.Contains(rels, "alternate") || !feedLinkTypes[linkType] || attrs["href"] == "" {
+			continue
+		}
+
+		ref, err := url.Parse(strings.TrimSpace(attrs["href"]))
+		if err != nil {
+			continue
+		}
+		feedURL := base.ResolveReference(ref).String()
+		if seen[feedURL] {
+			continue
+		}
+		seen[feedURL] = true
+
+		candidates = append(candidates, feedCandidate{
+			Url:   feedURL,
+			Title: strings.TrimSpace(attrs["title"]),
+			Type:  linkType,
+		})
+	}
+
+	return candidates, nil
+}
+
+func printCandidates(candidates []feedCandidate) {
+	for i, candidate := range candidates {
+		fmt.Printf("%d) %s\n", i+1, candidate.Url)
+		if candidate.Title != "" {
+			fmt.Printf("   %s\n", candidate.Title)
+		}
+	}
+}
+
+// chooseCandidate picks the only candidate, or asks which one to use when
+// running in a terminal.
+func chooseCandidate(pageURL string, candidates []feedCandidate) (feedCandidate, error) {
+	switch len(candidates) {
+	case 0:
+		return feedCandidate{}, fmt.Errorf("no feeds found at %s", pageURL)
+	case 1:
+		return candidates[0], nil
+	}
+
+	fmt.Printf("found %d feeds at %s:\n", len(candidates), pageURL)
+	printCandidates(candidates)
+
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return feedCandidate{}, fmt.Errorf("several feeds found, pass one of the urls above instead")
+	}
+
+	fmt.Printf("which one? ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return feedCandidate{}, fmt.Errorf("unable to read choice: %w", err)
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return feedCandidate{}, fmt.Errorf("invalid choice: %s", strings.TrimSpace(line))
+	}
+
+	return candidates[choice-1], nil
+}
+
+// findDiscoveredFeed resolves a page URL to a feed that has already been
+// added, for following a site by its homepage.
+func findDiscoveredFeed(ctx context.Context, s *state, pageURL string) (database.Feed, error) {
+	candidates, err := discoverFeeds(ctx, s, pageURL)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("unable to discover feeds: %w", err)
+	}
+
+	var known []feedCandidate
+	feeds := map[string]database.Feed{}
+	for _, candidate := range candidates {
+		feed, err := s.db.GetFeedByUrl(ctx, candidate.Url)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("unable to get feed by url: %w", err)
+		}
+		known = append(known, candidate)
+		feeds[candidate.Url] = feed
+	}
+
+	if len(known) == 0 {
+		if len(candidates) > 0 {
+			fmt.Printf("feeds found at %s that nobody has added yet:\n", pageURL)
+			printCandidates(candidates)
+		}
+		return database.Feed{}, fmt.Errorf("no added feed found at %s, add one with addfeed", pageURL)
+	}
+
+	candidate, err := chooseCandidate(pageURL, known)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	return feeds[candidate.Url], nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFeedLinks(t *testing.T) {
+	page := `<html><head>
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/rss.xml">
+<link rel="alternate" type="application/atom+xml" title="Atom" href="https://gators.example/atom.xml">
+<link rel="alternate" type="application/feed+json" title="JSON" href="/feed.json">
+<link rel="alternate" type="application/rss+xml" href="/rss.xml">
+<link rel="stylesheet" type="text/css" href="/style.css">
+</head></html>`
+	base, _ := url.Parse("https://gators.example/blog/")
+
+	candidates, err := feedLinks(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []feedCandidate{
+		{Url: "https://gators.example/rss.xml", Title: "RSS", Type: "application/rss+xml"},
+		{Url: "https://gators.example/atom.xml", Title: "Atom", Type: "application/atom+xml"},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("candidates = %+v, want %+v", candidates, want)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidate %d = %+v, want %+v", i, candidates[i], want[i])
+		}
+	}
+}
+
+func TestLooksLikeFeed(t *testing.T) {
+	tests := []struct {
+		contentType string
+		head        string
+		want        bool
+	}{
+		{"application/rss+xml", "", true},
+		{"application/atom+xml; charset=utf-8", "", true},
+		{"text/xml", `<?xml version="1.0"?><rss version="2.0">`, true},
+		{"text/xml", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom">`, true},
+		{"text/html", `<!doctype html><html><head><link rel="alternate" type="application/rss+xml">`, false},
+		{"application/feed+json", `{"version": "https://jsonfeed.org/version/1.1"}`, false},
+		{"application/json", `{"version": "https://jsonfeed.org/version/1.1"}`, false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLikeFeed(tt.contentType, []byte(tt.head)); got != tt.want {
+			t.Errorf("looksLikeFeed(%q, %q) = %v, want %v", tt.contentType, tt.head, got, tt.want)
+		}
+	}
+}
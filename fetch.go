@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"iter"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/kbm-ky/gator/internal/charset"
+)
+
+const (
+	feedConnectTimeout = 10 * time.Second
+	feedHeaderTimeout  = 20 * time.Second
+	// feedTimeout bounds the whole fetch, including reading a slow body.
+	feedTimeout = 60 * time.Second
+
+	defaultMaxFeedBytes = 10 << 20
+)
+
+var errFeedTooLarge = errors.New("feed exceeds maximum size")
+
+// feedClient is used for every feed fetch so a slow or unresponsive server
+// can't hold up agg. Compression is negotiated by openFeed rather than the
+// transport so brotli can be offered too.
+var feedClient = &http.Client{
+	Timeout:       feedTimeout,
+	CheckRedirect: trackRedirects,
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: feedConnectTimeout}).DialContext,
+		TLSHandshakeTimeout:   feedConnectTimeout,
+		ResponseHeaderTimeout: feedHeaderTimeout,
+		DisableCompression:    true,
+	},
+}
+
+type RSSChannel struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+
+	//Polling hints, see nextFetchAt
+	TTL             string   `xml:"ttl"`
+	SkipHours       []int    `xml:"skipHours>hour"`
+	SkipDays        []string `xml:"skipDays>day"`
+	UpdatePeriod    string   `xml:"updatePeriod"`
+	UpdateFrequency string   `xml:"updateFrequency"`
+
+	//WebSub hub and the topic URL to subscribe to
+	Hub  string `xml:"-"`
+	Self string `xml:"-"`
+}
+
+type RSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+
+	//Podcasts
+	Enclosures     []RSSEnclosure    `xml:"enclosure"`
+	MediaContent   []RSSMediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	ItunesDuration string            `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ItunesEpisode  string            `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ItunesSeason   string            `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+
+	//Full content, author and categories, see posts.go. AtomContent is only
+	//set from an Atom <entry>, see atom.go
+	ContentEncoded string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	AtomContent    string        `xml:"-"`
+	Author         RSSAuthor     `xml:"author"`
+	DcCreator      string        `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories     []RSSCategory `xml:"category"`
+	//slash:comments is a count and must come before <comments> to not clobber it
+	SlashComments string `xml:"http://purl.org/rss/1.0/modules/slash/ comments"`
+	Comments      string `xml:"comments"`
+}
+
+// feedStream is an open feed whose items are decoded as they are read off
+// the wire. Channel is filled in as its elements are passed, so it is only
+// complete once Items has been drained. PermanentURL is set when the feed
+// was reached through permanent redirects, and Cache holds the response's
+// freshness headers.
+type feedStream struct {
+	Channel      RSSChannel
+	PermanentURL string
+	Cache        cacheHints
+
+	body    io.ReadCloser
+	wire    *countingReader
+	counter *countingReader
+	decoder *xml.Decoder
+	start   time.Time
+}
+
+// openFeed requests a feed and prepares it for decoding, reading at most
+// maxBytes of decompressed body.
+func openFeed(ctx context.Context, feedURL string, maxBytes int64) (*feedStream, error) {
+	chain := &redirectChain{}
+	ctx = context.WithValue(ctx, redirectChainKey{}, chain)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make new requst: %w", err)
+	}
+	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+	start := time.Now()
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		feedFetches.Inc("error")
+		return nil, fmt.Errorf("unable to do request: %w", err)
+	}
+	feedFetches.Inc(strconv.Itoa(resp.StatusCode))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	//counter only sees the decompressed body, so count what came over the wire apart
+	wire := &countingReader{r: resp.Body}
+	decompressed, err := decompress(wire, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	f, err := newFeedStream(decompressed, resp.Header.Get("Content-Type"), maxBytes)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	f.PermanentURL = chain.permanentURL()
+	f.Cache = parseCacheHints(resp.Header, time.Now())
+	f.body = resp.Body
+	f.wire = wire
+	f.start = start
+
+	//WebSub says Link headers win over links in the feed itself
+	links := parseLinkHeader(resp.Header.Values("Link"))
+	f.Channel.Hub = links["hub"]
+	f.Channel.Self = links["self"]
+
+	return f, nil
+}
+
+// newFeedStream prepares a feed body that has already been retrieved, such
+// as one pushed by a WebSub hub, for decoding.
+func newFeedStream(body io.Reader, contentType string, maxBytes int64) (*feedStream, error) {
+	counter := &countingReader{r: body, max: maxBytes}
+	decoded, err := charset.NewReader(counter, contentType)
+	if err != nil {
+		feedParseFailures.Inc()
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(decoded)
+	decoder.CharsetReader = charset.Passthrough
+
+	return &feedStream{
+		counter: counter,
+		decoder: decoder,
+	}, nil
+}
+
+// Items decodes the feed one item at a time, reading both the items of an
+// RSS channel and the entries of an Atom feed. Iteration stops after the
+// first error.
+func (f *feedStream) Items() iter.Seq2[RSSItem, error] {
+	return func(yield func(RSSItem, error) bool) {
+		var path []string
+		atom := false
+		for {
+			tok, err := f.decoder.Token()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(RSSItem{}, parseFailure(fmt.Errorf("unable to decode xml: %w", err)))
+				return
+			}
+
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if len(path) == 0 && t.Name.Space == atomNS && t.Name.Local == "feed" {
+					atom = true
+				}
+
+				var item *RSSItem
+				switch {
+				case atom && len(path) == 1:
+					item, err = f.decodeFeedElement(&t)
+				case !atom && len(path) == 2 && path[1] == "channel":
+					item, err = f.decodeChannelElement(&t)
+				default:
+					path = append(path, t.Name.Local)
+					continue
+				}
+				if err != nil {
+					yield(RSSItem{}, parseFailure(err))
+					return
+				}
+				if item != nil && !yield(*item, nil) {
+					return
+				}
+			case xml.EndElement:
+				if len(path) > 0 {
+					path = path[:len(path)-1]
+				}
+			}
+		}
+	}
+}
+
+// decodeChannelElement decodes one child of an RSS <channel>, returning it
+// when it is an item.
+func (f *feedStream) decodeChannelElement(t *xml.StartElement) (*RSSItem, error) {
+	switch t.Name.Local {
+	case "item":
+		var item RSSItem
+		if err := f.decoder.DecodeElement(&item, t); err != nil {
+			return nil, fmt.Errorf("unable to decode item: %w", err)
+		}
+		item.Title = html.UnescapeString(item.Title)
+		item.Description = html.UnescapeString(item.Description)
+		return &item, nil
+	case "skipHours":
+		var skip struct {
+			Hours []int `xml:"hour"`
+		}
+		if err := f.decoder.DecodeElement(&skip, t); err != nil {
+			return nil, fmt.Errorf("unable to decode channel skipHours: %w", err)
+		}
+		f.Channel.SkipHours = skip.Hours
+	case "skipDays":
+		var skip struct {
+			Days []string `xml:"day"`
+		}
+		if err := f.decoder.DecodeElement(&skip, t); err != nil {
+			return nil, fmt.Errorf("unable to decode channel skipDays: %w", err)
+		}
+		f.Channel.SkipDays = skip.Days
+	case "link":
+		var text string
+		if err := f.decoder.DecodeElement(&text, t); err != nil {
+			return nil, fmt.Errorf("unable to decode channel link: %w", err)
+		}
+		f.setChannelLink(*t, strings.TrimSpace(text))
+	case "title", "description", "ttl", "updatePeriod", "updateFrequency":
+		var text string
+		if err := f.decoder.DecodeElement(&text, t); err != nil {
+			return nil, fmt.Errorf("unable to decode channel %s: %w", t.Name.Local, err)
+		}
+		f.setChannelField(t.Name.Local, html.UnescapeString(text))
+	default:
+		if err := f.decoder.Skip(); err != nil {
+			return nil, fmt.Errorf("unable to decode channel %s: %w", t.Name.Local, err)
+		}
+	}
+	return nil, nil
+}
+
+// decodeFeedElement decodes one child of an Atom <feed>, returning it as an
+// item when it is an entry.
+func (f *feedStream) decodeFeedElement(t *xml.StartElement) (*RSSItem, error) {
+	switch t.Name.Local {
+	case "entry":
+		var entry atomEntry
+		if err := f.decoder.DecodeElement(&entry, t); err != nil {
+			return nil, fmt.Errorf("unable to decode entry: %w", err)
+		}
+		item := entry.item()
+		return &item, nil
+	case "link":
+		var text string
+		if err := f.decoder.DecodeElement(&text, t); err != nil {
+			return nil, fmt.Errorf("unable to decode feed link: %w", err)
+		}
+		f.setChannelLink(*t, strings.TrimSpace(text))
+	case "title", "subtitle":
+		var text atomText
+		if err := f.decoder.DecodeElement(&text, t); err != nil {
+			return nil, fmt.Errorf("unable to decode feed %s: %w", t.Name.Local, err)
+		}
+		if t.Name.Local == "title" {
+			f.Channel.Title = text.plain()
+		} else {
+			f.Channel.Description = text.plain()
+		}
+	default:
+		if err := f.decoder.Skip(); err != nil {
+			return nil, fmt.Errorf("unable to decode feed %s: %w", t.Name.Local, err)
+		}
+	}
+	return nil, nil
+}
+
+// parseFailure counts err as a parse failure unless the feed was simply cut
+// off for being too large.
+func parseFailure(err error) error {
+	if !errors.Is(err, errFeedTooLarge) {
+		feedParseFailures.Inc()
+	}
+	return err
+}
+
+func (f *feedStream) setChannelField(name, value string) {
+	switch name {
+	case "title":
+		f.Channel.Title = value
+	case "description":
+		f.Channel.Description = value
+	case "ttl":
+		f.Channel.TTL = value
+	case "updatePeriod":
+		f.Channel.UpdatePeriod = value
+	case "updateFrequency":
+		f.Channel.UpdateFrequency = value
+	}
+}
+
+// setChannelLink handles both the RSS <link> and atom:link, which shares
+// its local name but carries its value in href and names WebSub hubs. An
+// Atom feed's own link is its alternate one.
+func (f *feedStream) setChannelLink(t xml.StartElement, text string) {
+	var rel, href string
+	for _, attr := range t.Attr {
+		switch attr.Name.Local {
+		case "rel":
+			rel = attr.Value
+		case "href":
+			href = attr.Value
+		}
+	}
+
+	switch {
+	case rel == "hub" && f.Channel.Hub == "":
+		f.Channel.Hub = href
+	case rel == "self" && f.Channel.Self == "":
+		f.Channel.Self = href
+	case href == "" && text != "":
+		f.Channel.Link = text
+	case (rel == "" || rel == "alternate") && href != "" && f.Channel.Link == "":
+		f.Channel.Link = href
+	}
+}
+
+// Close releases the connection and records how long the feed took to
+// fetch and store and how much was read. Streams that weren't fetched have
+// nothing to close.
+func (f *feedStream) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	feedScrapeDuration.Observe(time.Since(f.start).Seconds())
+	feedFetchBytes.Add(float64(f.wire.n))
+	return f.body.Close()
+}
+
+func decompress(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read gzip body: %w", err)
+		}
+		return r, nil
+	case "deflate":
+		//deflate is meant to be zlib wrapped, but plenty of servers send it raw
+		br := bufio.NewReader(body)
+		head, err := br.Peek(2)
+		if err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			r, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read deflate body: %w", err)
+			}
+			return r, nil
+		}
+		return flate.NewReader(br), nil
+	case "br":
+		return brotli.NewReader(body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// countingReader counts the bytes read through it and fails once more than
+// max have been read, so a compressed body can't expand without bound.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.max > 0 && c.n > c.max {
+		return n, errFeedTooLarge
+	}
+	return n, err
+}
+
+func maxFeedBytes(s *state) int64 {
+	if s.cfg.MaxFeedBytes > 0 {
+		return s.cfg.MaxFeedBytes
+	}
+	return defaultMaxFeedBytes
+}
+
+// parseLinkHeader maps the rel of each link in HTTP Link headers to its URL,
+// keeping the first link for each rel.
+func parseLinkHeader(values []string) map[string]string {
+	links := map[string]string{}
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = strings.Trim(target, "<>")
+
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					rel = strings.ToLower(rel)
+					if _, seen := links[rel]; !seen {
+						links[rel] = target
+					}
+				}
+			}
+		}
+	}
+	return links
+}
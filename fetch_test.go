@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readTestFeed(t *testing.T, name string) (*feedStream, []RSSItem) {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := newFeedStream(strings.NewReader(string(body)), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []RSSItem
+	for item, err := range stream.Items() {
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", name, err)
+		}
+		items = append(items, item)
+	}
+	return stream, items
+}
+
+func TestItemsAtom(t *testing.T) {
+	stream, items := readTestFeed(t, "atom.xml")
+
+	channel := stream.Channel
+	if channel.Title != "Gator & Friends" || channel.Description != "News from the swamp" {
+		t.Errorf("title %q, description %q", channel.Title, channel.Description)
+	}
+	if channel.Link != "https://gators.example/" {
+		t.Errorf("link = %q", channel.Link)
+	}
+	if channel.Hub != "https://hub.example/" || channel.Self != "https://gators.example/atom.xml" {
+		t.Errorf("hub %q, self %q", channel.Hub, channel.Self)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	item := items[0]
+	if item.Title != "Crocs <3 mud" {
+		t.Errorf("title = %q", item.Title)
+	}
+	if item.Link != "https://gators.example/crocs" {
+		t.Errorf("link = %q", item.Link)
+	}
+	if item.PubDate != "2024-03-05T09:00:00Z" {
+		t.Errorf("pub date = %q, want the published date", item.PubDate)
+	}
+	if item.Description != "Mud is &lt;great&gt;" {
+		t.Errorf("description = %q, want escaped text", item.Description)
+	}
+	if item.content() != "<p>Crocs love <b>mud</b>.</p>" {
+		t.Errorf("content = %q", item.content())
+	}
+	if item.author() != "Ally Gator" {
+		t.Errorf("author = %q", item.author())
+	}
+	if got := item.categories(); !slices.Equal(got, []string{"reptiles", "mud"}) {
+		t.Errorf("categories = %q", got)
+	}
+	if encs := item.enclosures(); len(encs) != 1 || encs[0].Url != "https://gators.example/crocs.mp3" || encs[0].Length != "1234" {
+		t.Errorf("enclosures = %+v", encs)
+	}
+
+	item = items[1]
+	if item.PubDate != "2024-03-06T10:00:00Z" {
+		t.Errorf("pub date = %q, want the updated date", item.PubDate)
+	}
+	if item.Link != "https://gators.example/updated" {
+		t.Errorf("link = %q", item.Link)
+	}
+	if !strings.Contains(item.content(), "<p>Inline <em>xhtml</em></p>") {
+		t.Errorf("content = %q", item.content())
+	}
+	if item.Description != item.content() {
+		t.Errorf("description = %q, want the content when there's no summary", item.Description)
+	}
+}
+
+func TestItemsRSS(t *testing.T) {
+	body := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+<title>Gators</title>
+<link>https://gators.example/</link>
+<atom:link rel="self" href="https://gators.example/rss.xml"/>
+<image><title>not the channel title</title></image>
+<ttl>30</ttl>
+<item><title>One</title><link>https://gators.example/1</link></item>
+<item><title>Two</title><link>https://gators.example/2</link></item>
+</channel>
+</rss>`
+	stream, err := newFeedStream(strings.NewReader(body), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var links []string
+	for item, err := range stream.Items() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		links = append(links, item.Link)
+	}
+	if !slices.Equal(links, []string{"https://gators.example/1", "https://gators.example/2"}) {
+		t.Errorf("links = %q", links)
+	}
+	if stream.Channel.Title != "Gators" || stream.Channel.Link != "https://gators.example/" || stream.Channel.TTL != "30" {
+		t.Errorf("channel = %+v", stream.Channel)
+	}
+	if stream.Channel.Self != "https://gators.example/rss.xml" {
+		t.Errorf("self = %q", stream.Channel.Self)
+	}
+}
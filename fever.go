@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/sanitize"
+)
+
+// Fever clients expect at most 50 items per request.
+const feverItemLimit = 50
+
+// Every followed feed lives in the "All" group, and each of the user's tags
+// is a group of its own numbered after it.
+const feverAllGroupID = 1
+
+// errFeverBadRequest marks errors caused by the client's request rather
+// than by gator, which are answered with a 400.
+var errFeverBadRequest = errors.New("bad request")
+
+type feverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	Url               string `json:"url"`
+	SiteUrl           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	Html          string `json:"html"`
+	Url           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+func registerFeverRoutes(s *state, mux *http.ServeMux) {
+	mux.HandleFunc("/fever/", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := handleFever(r.Context(), s, r)
+		if errors.Is(err, errFeverBadRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.logger.Error("fever request failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := writeJSON(w, resp); err != nil {
+			s.logger.Error("unable to encode fever response", "error", err)
+		}
+	})
+}
+
+// handleFever answers a single Fever API call. Every call is authenticated
+// by api_key and may combine several of the read and mark operations.
+func handleFever(ctx context.Context, s *state, r *http.Request) (map[string]any, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse form: %w", errFeverBadRequest, err)
+	}
+
+	resp := map[string]any{
+		"api_version": 3,
+		"auth":        0,
+	}
+
+	user, err := s.db.GetUserByFeverKey(ctx, strings.ToLower(r.FormValue("api_key")))
+	if err != nil {
+		return resp, nil
+	}
+	resp["auth"] = 1
+
+	feeds, err := s.db.GetFeedsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get feeds for user: %w", err)
+	}
+
+	var lastRefreshed int64
+	for _, feed := range feeds {
+		if feed.LastFetchedAt.Valid && feed.LastFetchedAt.Time.Unix() > lastRefreshed {
+			lastRefreshed = feed.LastFetchedAt.Time.Unix()
+		}
+	}
+	resp["last_refreshed_on_time"] = lastRefreshed
+
+	if r.Form.Has("mark") {
+		if err := feverMark(ctx, s, r, user); err != nil {
+			return nil, err
+		}
+	}
+
+	var groups []feverGroup
+	var feedsGroups []feverFeedsGroup
+	if r.Form.Has("groups") || r.Form.Has("feeds") {
+		groups, feedsGroups, err = feverGroups(ctx, s, user, feeds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Form.Has("groups") {
+		resp["groups"] = groups
+		resp["feeds_groups"] = feedsGroups
+	}
+
+	if r.Form.Has("feeds") {
+		titles, err := followTitles(ctx, s, user)
+		if err != nil {
+			return nil, err
+		}
+
+		feverFeeds := []feverFeed{}
+		for _, feed := range feeds {
+			feverFeeds = append(feverFeeds, feverFeed{
+				ID:                feed.Seq,
+				Title:             titles[feed.ID],
+				Url:               feed.Url,
+				SiteUrl:           feed.Url,
+				LastUpdatedOnTime: feed.LastFetchedAt.Time.Unix(),
+			})
+		}
+		resp["feeds"] = feverFeeds
+		resp["feeds_groups"] = feedsGroups
+	}
+
+	if r.Form.Has("favicons") {
+		resp["favicons"] = []any{}
+	}
+
+	if r.Form.Has("links") {
+		resp["links"] = []any{}
+	}
+
+	if r.Form.Has("items") {
+		items, err := feverItems(ctx, s, r, user)
+		if err != nil {
+			return nil, err
+		}
+		resp["items"] = items
+
+		total, err := s.db.CountPostsForUser(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to count posts for user: %w", err)
+		}
+		resp["total_items"] = total
+	}
+
+	if r.Form.Has("unread_item_ids") || r.Form.Has("mark") {
+		seqs, err := s.db.GetUnreadPostSeqsForUser(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get unread posts: %w", err)
+		}
+		resp["unread_item_ids"] = joinSeqs(seqs)
+	}
+
+	if r.Form.Has("saved_item_ids") || r.Form.Has("mark") {
+		seqs, err := s.db.GetStarredPostSeqsForUser(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get saved posts: %w", err)
+		}
+		resp["saved_item_ids"] = joinSeqs(seqs)
+	}
+
+	return resp, nil
+}
+
+// feverGroupID numbers a tag's group after the "All" group.
+func feverGroupID(tagSeq int64) int64 {
+	return tagSeq + feverAllGroupID
+}
+
+// feverGroups lists the "All" group and one group per tag, along with the
+// feeds in each.
+func feverGroups(ctx context.Context, s *state, user database.User, feeds []database.Feed) ([]feverGroup, []feverFeedsGroup, error) {
+	seqs := make([]int64, 0, len(feeds))
+	feedSeqs := map[uuid.UUID]int64{}
+	for _, feed := range feeds {
+		seqs = append(seqs, feed.Seq)
+		feedSeqs[feed.ID] = feed.Seq
+	}
+
+	groups := []feverGroup{{ID: feverAllGroupID, Title: "All"}}
+	feedsGroups := []feverFeedsGroup{{GroupID: feverAllGroupID, FeedIDs: joinSeqs(seqs)}}
+
+	rows, err := s.db.GetFeedTagsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get feed tags for user: %w", err)
+	}
+
+	//rows come sorted by tag, so each tag's feeds are together
+	var tagged []int64
+	for i, row := range rows {
+		tagged = append(tagged, feedSeqs[row.FeedID])
+		if i+1 < len(rows) && rows[i+1].TagSeq == row.TagSeq {
+			continue
+		}
+
+		id := feverGroupID(row.TagSeq)
+		groups = append(groups, feverGroup{ID: id, Title: row.TagName})
+		feedsGroups = append(feedsGroups, feverFeedsGroup{GroupID: id, FeedIDs: joinSeqs(tagged)})
+		tagged = nil
+	}
+
+	return groups, feedsGroups, nil
+}
+
+func feverItems(ctx context.Context, s *state, r *http.Request, user database.User) ([]feverItem, error) {
+	var rows []database.GetItemsForUserSinceRow
+
+	switch {
+	case r.Form.Has("with_ids"):
+		seqs, err := splitSeqs(r.FormValue("with_ids"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errFeverBadRequest, err)
+		}
+		if len(seqs) > feverItemLimit {
+			seqs = seqs[:feverItemLimit]
+		}
+
+		args := database.GetItemsForUserBySeqsParams{
+			UserID: user.ID,
+			Seqs:   seqs,
+		}
+		found, err := s.db.GetItemsForUserBySeqs(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get items by id: %w", err)
+		}
+		for _, row := range found {
+			rows = append(rows, database.GetItemsForUserSinceRow(row))
+		}
+
+	case r.Form.Has("max_id"):
+		maxID, err := strconv.ParseInt(r.FormValue("max_id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse max_id: %w", errFeverBadRequest, err)
+		}
+
+		args := database.GetItemsForUserBeforeParams{
+			UserID: user.ID,
+			Seq:    maxID,
+			Limit:  feverItemLimit,
+		}
+		found, err := s.db.GetItemsForUserBefore(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get items before id: %w", err)
+		}
+		for _, row := range found {
+			rows = append(rows, database.GetItemsForUserSinceRow(row))
+		}
+
+	default:
+		var sinceID int64
+		if r.Form.Has("since_id") {
+			var err error
+			sinceID, err = strconv.ParseInt(r.FormValue("since_id"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to parse since_id: %w", errFeverBadRequest, err)
+			}
+		}
+
+		args := database.GetItemsForUserSinceParams{
+			UserID: user.ID,
+			Seq:    sinceID,
+			Limit:  feverItemLimit,
+		}
+		var err error
+		rows, err = s.db.GetItemsForUserSince(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get items since id: %w", err)
+		}
+	}
+
+	items := []feverItem{}
+	for _, row := range rows {
+		item := feverItem{
+			ID:            row.Seq,
+			FeedID:        row.FeedSeq,
+			Title:         row.Title.String,
+			Html:          sanitize.HTML(row.Description.String),
+			Url:           row.Url,
+			CreatedOnTime: postTime(row.PublishedAt, row.CreatedAt).Unix(),
+		}
+		if row.Read {
+			item.IsRead = 1
+		}
+		if row.Starred {
+			item.IsSaved = 1
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// feverMark applies mark=item|feed|group with as=read|unread|saved|unsaved.
+func feverMark(ctx context.Context, s *state, r *http.Request, user database.User) error {
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse id: %w", errFeverBadRequest, err)
+	}
+	as := r.FormValue("as")
+	now := time.Now()
+
+	switch r.FormValue("mark") {
+	case "item":
+		postArgs := database.GetPostForUserBySeqParams{
+			UserID: user.ID,
+			Seq:    id,
+		}
+		post, err := s.db.GetPostForUserBySeq(ctx, postArgs)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no item %d among the feeds you follow", errFeverBadRequest, id)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to get post by id: %w", err)
+		}
+
+		switch as {
+		case "read", "unread":
+			args := database.SetPostReadParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				UserID:    user.ID,
+				PostID:    post.ID,
+				Read:      as == "read",
+			}
+			if err := s.db.SetPostRead(ctx, args); err != nil {
+				return fmt.Errorf("unable to mark post %s: %w", as, err)
+			}
+		case "saved", "unsaved":
+			args := database.SetPostStarredParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				UserID:    user.ID,
+				PostID:    post.ID,
+				Starred:   as == "saved",
+			}
+			if err := s.db.SetPostStarred(ctx, args); err != nil {
+				return fmt.Errorf("unable to mark post %s: %w", as, err)
+			}
+		default:
+			return fmt.Errorf("%w: unknown item mark: %s", errFeverBadRequest, as)
+		}
+
+	case "feed":
+		before, err := feverBefore(r)
+		if err != nil {
+			return err
+		}
+		feedArgs := database.GetFeedForUserBySeqParams{
+			UserID: user.ID,
+			Seq:    id,
+		}
+		feed, err := s.db.GetFeedForUserBySeq(ctx, feedArgs)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no feed %d among the feeds you follow", errFeverBadRequest, id)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to get feed by id: %w", err)
+		}
+
+		args := database.MarkFeedReadForUserParams{
+			Now:    now,
+			UserID: user.ID,
+			FeedID: feed.ID,
+			Before: before,
+		}
+		if err := s.db.MarkFeedReadForUser(ctx, args); err != nil {
+			return fmt.Errorf("unable to mark feed read: %w", err)
+		}
+
+	case "group":
+		before, err := feverBefore(r)
+		if err != nil {
+			return err
+		}
+
+		//group 0 is the "Kindling" super group, which is everything as well
+		if id <= feverAllGroupID {
+			args := database.MarkAllReadForUserParams{
+				Now:    now,
+				UserID: user.ID,
+				Before: before,
+			}
+			if err := s.db.MarkAllReadForUser(ctx, args); err != nil {
+				return fmt.Errorf("unable to mark group read: %w", err)
+			}
+			break
+		}
+
+		tags, err := s.db.GetTagsForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("unable to get tags for user: %w", err)
+		}
+		for _, tag := range tags {
+			if feverGroupID(tag.Seq) != id {
+				continue
+			}
+			args := database.MarkTagReadForUserParams{
+				Now:    now,
+				UserID: user.ID,
+				Tag:    tag.Name,
+				Before: before,
+			}
+			if err := s.db.MarkTagReadForUser(ctx, args); err != nil {
+				return fmt.Errorf("unable to mark group read: %w", err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("%w: unknown mark: %s", errFeverBadRequest, r.FormValue("mark"))
+	}
+
+	return nil
+}
+
+func feverBefore(r *http.Request) (time.Time, error) {
+	before, err := strconv.ParseInt(r.FormValue("before"), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: unable to parse before: %w", errFeverBadRequest, err)
+	}
+
+	return time.Unix(before, 0), nil
+}
+
+func joinSeqs(seqs []int64) string {
+	parts := make([]string, 0, len(seqs))
+	for _, seq := range seqs {
+		parts = append(parts, strconv.FormatInt(seq, 10))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func splitSeqs(list string) ([]int64, error) {
+	var seqs []int64
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		seq, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse id: %s [%w]", part, err)
+		}
+		seqs = append(seqs, seq)
+	}
+
+	return seqs, nil
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+// feverFixture is a Fever server for alice, who follows two feeds, one of
+// them tagged "reptiles". Bob's feed is there to show it never leaks.
+type feverFixture struct {
+	s      *state
+	server *httptest.Server
+	key    string
+
+	gators, swamps, bobs database.Feed
+	tag                  database.Tag
+
+	//posts by feed, oldest first
+	gatorPosts, swampPosts []database.Post
+	bobPost                database.Post
+
+	//between the old posts and the new ones
+	before time.Time
+}
+
+func newFeverFixture(t *testing.T) *feverFixture {
+	t.Helper()
+	s := newTestState(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	if err := saveApiPassword(ctx, s, alice, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	bob := createTestUser(t, s, "bob")
+
+	f := &feverFixture{s: s, key: feverKey("alice", "secret")}
+	f.gators = createTestFeed(t, s, alice, "Gators", "https://gators.example/feed")
+	f.swamps = createTestFeed(t, s, alice, "Swamps", "https://swamps.example/feed")
+	f.bobs = createTestFeed(t, s, bob, "Bob's", "https://bob.example/feed")
+
+	now := time.Now()
+	f.before = now.Add(-30 * time.Minute)
+	for i, age := range []time.Duration{2 * time.Hour, time.Hour, 0} {
+		f.gatorPosts = append(f.gatorPosts, createTestPost(t, s, f.gators, fmt.Sprintf("https://gators.example/%d", i), now.Add(-age)))
+	}
+	for i, age := range []time.Duration{2 * time.Hour, 0} {
+		f.swampPosts = append(f.swampPosts, createTestPost(t, s, f.swamps, fmt.Sprintf("https://swamps.example/%d", i), now.Add(-age)))
+	}
+	f.bobPost = createTestPost(t, s, f.bobs, "https://bob.example/0", now)
+
+	tag, err := s.db.UpsertTag(ctx, database.UpsertTagParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    alice.ID,
+		Name:      "reptiles",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.tag = tag
+	_, follow, err := followedFeed(ctx, s, alice, f.gators.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.db.AddFeedFollowTag(ctx, database.AddFeedFollowTagParams{
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		FeedFollowID: follow.ID,
+		TagID:        tag.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	registerFeverRoutes(s, mux)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+// call posts form to the Fever endpoint as alice, with the query read as
+// the Fever operations to run.
+func (f *feverFixture) call(t *testing.T, query string, form url.Values) map[string]any {
+	t.Helper()
+	if form == nil {
+		form = url.Values{}
+	}
+	if !form.Has("api_key") {
+		form.Set("api_key", f.key)
+	}
+
+	resp, err := http.PostForm(f.server.URL+"/fever/?api&"+query, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+
+	var decoded map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func seqList(posts ...database.Post) string {
+	seqs := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		seqs = append(seqs, post.Seq)
+	}
+	slices.Sort(seqs)
+	return joinSeqs(seqs)
+}
+
+// itemIDs lists the ids of the items in a response, in order.
+func itemIDs(t *testing.T, resp map[string]any) []int64 {
+	t.Helper()
+	items, ok := resp["items"].([]any)
+	if !ok {
+		t.Fatalf("no items in %v", resp)
+	}
+	var ids []int64
+	for _, item := range items {
+		ids = append(ids, int64(item.(map[string]any)["id"].(float64)))
+	}
+	return ids
+}
+
+func TestFeverAuth(t *testing.T) {
+	f := newFeverFixture(t)
+
+	resp := f.call(t, "feeds", nil)
+	if resp["auth"] != float64(1) || resp["api_version"] != float64(3) {
+		t.Errorf("unexpected response for a good key: %v", resp)
+	}
+
+	for _, key := range []string{"", "nonsense", feverKey("alice", "wrong"), feverKey("bob", "secret")} {
+		resp := f.call(t, "feeds&items", url.Values{"api_key": {key}})
+		if resp["auth"] != float64(0) {
+			t.Errorf("key %q authenticated", key)
+		}
+		if _, ok := resp["feeds"]; ok {
+			t.Errorf("key %q got feeds", key)
+		}
+		if _, ok := resp["items"]; ok {
+			t.Errorf("key %q got items", key)
+		}
+	}
+
+	//Fever clients may send the key in either case
+	resp = f.call(t, "", url.Values{"api_key": {strings.ToUpper(f.key)}})
+	if resp["auth"] != float64(1) {
+		t.Error("an upper case key didn't authenticate")
+	}
+}
+
+func TestFeverGroups(t *testing.T) {
+	f := newFeverFixture(t)
+	resp := f.call(t, "groups", nil)
+
+	groupID := feverGroupID(f.tag.Seq)
+	wantGroups := []any{
+		map[string]any{"id": float64(feverAllGroupID), "title": "All"},
+		map[string]any{"id": float64(groupID), "title": "reptiles"},
+	}
+	if got, _ := json.Marshal(resp["groups"]); string(got) != mustJSON(t, wantGroups) {
+		t.Errorf("groups = %s, want %s", got, mustJSON(t, wantGroups))
+	}
+
+	feedIDs := []int64{f.gators.Seq, f.swamps.Seq}
+	slices.Sort(feedIDs)
+	wantFeedsGroups := []any{
+		map[string]any{"group_id": float64(feverAllGroupID), "feed_ids": joinSeqs(feedIDs)},
+		map[string]any{"group_id": float64(groupID), "feed_ids": strconv.FormatInt(f.gators.Seq, 10)},
+	}
+	if got, _ := json.Marshal(resp["feeds_groups"]); string(got) != mustJSON(t, wantFeedsGroups) {
+		t.Errorf("feeds_groups = %s, want %s", got, mustJSON(t, wantFeedsGroups))
+	}
+
+	//feeds carries feeds_groups too
+	resp = f.call(t, "feeds", nil)
+	if got, _ := json.Marshal(resp["feeds_groups"]); string(got) != mustJSON(t, wantFeedsGroups) {
+		t.Errorf("feeds_groups with feeds = %s, want %s", got, mustJSON(t, wantFeedsGroups))
+	}
+	if feeds, _ := resp["feeds"].([]any); len(feeds) != 2 {
+		t.Errorf("got %d feeds, want 2", len(feeds))
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	blob, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(blob)
+}
+
+func TestFeverItems(t *testing.T) {
+	f := newFeverFixture(t)
+	all := []database.Post{f.gatorPosts[0], f.gatorPosts[1], f.gatorPosts[2], f.swampPosts[0], f.swampPosts[1]}
+	var seqs []int64
+	for _, post := range all {
+		seqs = append(seqs, post.Seq)
+	}
+	slices.Sort(seqs)
+
+	resp := f.call(t, "items", nil)
+	if got := itemIDs(t, resp); !slices.Equal(got, seqs) {
+		t.Errorf("items = %v, want %v", got, seqs)
+	}
+	if resp["total_items"] != float64(len(all)) {
+		t.Errorf("total_items = %v, want %d", resp["total_items"], len(all))
+	}
+
+	resp = f.call(t, "items&since_id="+strconv.FormatInt(seqs[2], 10), nil)
+	if got := itemIDs(t, resp); !slices.Equal(got, seqs[3:]) {
+		t.Errorf("since_id items = %v, want %v", got, seqs[3:])
+	}
+
+	//max_id pages backwards, newest first
+	resp = f.call(t, "items&max_id="+strconv.FormatInt(seqs[2], 10), nil)
+	want := []int64{seqs[1], seqs[0]}
+	if got := itemIDs(t, resp); !slices.Equal(got, want) {
+		t.Errorf("max_id items = %v, want %v", got, want)
+	}
+
+	//bob's post isn't alice's to read
+	withIDs := []int64{seqs[4], seqs[0], f.bobPost.Seq}
+	resp = f.call(t, "items&with_ids="+joinSeqs(withIDs), nil)
+	want = []int64{seqs[0], seqs[4]}
+	if got := itemIDs(t, resp); !slices.Equal(got, want) {
+		t.Errorf("with_ids items = %v, want %v", got, want)
+	}
+}
+
+func TestFeverItemIDs(t *testing.T) {
+	f := newFeverFixture(t)
+
+	resp := f.call(t, "unread_item_ids&saved_item_ids", nil)
+	wantUnread := seqList(append(slices.Clone(f.gatorPosts), f.swampPosts...)...)
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+	if resp["saved_item_ids"] != "" {
+		t.Errorf("saved_item_ids = %v, want none", resp["saved_item_ids"])
+	}
+}
+
+func TestFeverMarkItem(t *testing.T) {
+	f := newFeverFixture(t)
+	post := f.gatorPosts[1]
+	id := strconv.FormatInt(post.Seq, 10)
+
+	resp := f.call(t, "", url.Values{"mark": {"item"}, "as": {"read"}, "id": {id}})
+	wantUnread := seqList(f.gatorPosts[0], f.gatorPosts[2], f.swampPosts[0], f.swampPosts[1])
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("after read, unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+
+	resp = f.call(t, "", url.Values{"mark": {"item"}, "as": {"unread"}, "id": {id}})
+	wantUnread = seqList(append(slices.Clone(f.gatorPosts), f.swampPosts...)...)
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("after unread, unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+
+	resp = f.call(t, "", url.Values{"mark": {"item"}, "as": {"saved"}, "id": {id}})
+	if resp["saved_item_ids"] != id {
+		t.Errorf("after saved, saved_item_ids = %v, want %s", resp["saved_item_ids"], id)
+	}
+	resp = f.call(t, "items&with_ids="+id, nil)
+	if item := resp["items"].([]any)[0].(map[string]any); item["is_saved"] != float64(1) {
+		t.Errorf("saved item has is_saved = %v", item["is_saved"])
+	}
+
+	resp = f.call(t, "", url.Values{"mark": {"item"}, "as": {"unsaved"}, "id": {id}})
+	if resp["saved_item_ids"] != "" {
+		t.Errorf("after unsaved, saved_item_ids = %v, want none", resp["saved_item_ids"])
+	}
+}
+
+func TestFeverMarkFeed(t *testing.T) {
+	f := newFeverFixture(t)
+
+	resp := f.call(t, "", url.Values{
+		"mark":   {"feed"},
+		"as":     {"read"},
+		"id":     {strconv.FormatInt(f.gators.Seq, 10)},
+		"before": {strconv.FormatInt(f.before.Unix(), 10)},
+	})
+
+	//only the gator posts from before "before" are read
+	wantUnread := seqList(f.gatorPosts[2], f.swampPosts[0], f.swampPosts[1])
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+}
+
+func TestFeverMarkFeedByPublished(t *testing.T) {
+	f := newFeverFixture(t)
+
+	//Fetched just now, but published before "before", which is the time
+	//items report
+	_, err := f.s.conn.Exec("UPDATE posts SET published_at = $1 WHERE id = $2", f.before.Add(-time.Hour), f.gatorPosts[2].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := f.call(t, "", url.Values{
+		"mark":   {"feed"},
+		"as":     {"read"},
+		"id":     {strconv.FormatInt(f.gators.Seq, 10)},
+		"before": {strconv.FormatInt(f.before.Unix(), 10)},
+	})
+	wantUnread := seqList(f.swampPosts[0], f.swampPosts[1])
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+}
+
+func TestFeverMarkGroup(t *testing.T) {
+	f := newFeverFixture(t)
+	before := strconv.FormatInt(f.before.Unix(), 10)
+
+	//the tag's group only covers the gator feed
+	resp := f.call(t, "", url.Values{
+		"mark":   {"group"},
+		"as":     {"read"},
+		"id":     {strconv.FormatInt(feverGroupID(f.tag.Seq), 10)},
+		"before": {before},
+	})
+	wantUnread := seqList(f.gatorPosts[2], f.swampPosts[0], f.swampPosts[1])
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("after the tag group, unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+
+	//"All" covers every feed
+	resp = f.call(t, "", url.Values{
+		"mark":   {"group"},
+		"as":     {"read"},
+		"id":     {strconv.Itoa(feverAllGroupID)},
+		"before": {before},
+	})
+	wantUnread = seqList(f.gatorPosts[2], f.swampPosts[1])
+	if resp["unread_item_ids"] != wantUnread {
+		t.Errorf("after All, unread_item_ids = %v, want %s", resp["unread_item_ids"], wantUnread)
+	}
+}
+
+func TestFeverBadRequests(t *testing.T) {
+	f := newFeverFixture(t)
+
+	for _, query := range []string{
+		"items&since_id=abc",
+		"items&max_id=abc",
+		"items&with_ids=1,x",
+		"mark=item&as=read&id=abc",
+		"mark=item&as=sideways&id=" + strconv.FormatInt(f.gatorPosts[0].Seq, 10),
+		"mark=feed&as=read&id=1&before=abc",
+		"mark=everything&as=read&id=1",
+		//ids outside alice's follows
+		"mark=item&as=read&id=" + strconv.FormatInt(f.bobPost.Seq, 10),
+		"mark=item&as=saved&id=999999",
+		"mark=feed&as=read&id=" + strconv.FormatInt(f.bobs.Seq, 10) + "&before=" + strconv.FormatInt(time.Now().Unix(), 10),
+	} {
+		resp, err := http.PostForm(f.server.URL+"/fever/?api&"+query, url.Values{"api_key": {f.key}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestFeverBadForm needs no database, since the form is parsed before the
+// api key is looked up.
+func TestFeverBadForm(t *testing.T) {
+	s := &state{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	mux := http.NewServeMux()
+	registerFeverRoutes(s, mux)
+
+	for _, target := range []string{"/fever/?api&since_id=%zz", "/fever/?api"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("api_key=%zz"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
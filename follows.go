@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+// followLabel is how following lists a feed: the user's title for it, and
+// its priority when it has one.
+func followLabel(follow database.GetFeedFollowsForUserRow) string {
+	if follow.Priority == 0 {
+		return follow.FeedName
+	}
+	return fmt.Sprintf("%s (priority %d)", follow.FeedName, follow.Priority)
+}
+
+// followTitles maps each of a user's followed feeds to the title the user
+// sees, which is the feed's name unless they renamed it.
+func followTitles(ctx context.Context, s *state, user database.User) (map[uuid.UUID]string, error) {
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get feed follows for user: %w", err)
+	}
+
+	titles := map[uuid.UUID]string{}
+	for _, follow := range follows {
+		titles[follow.FeedID] = follow.FeedName
+	}
+	return titles, nil
+}
+
+func handlerRename(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("rename expects at least 1 argument: feed_url [title]")
+	}
+	ctx := context.Background()
+
+	feed, _, err := followedFeed(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	//No title goes back to the feed's own name
+	title := strings.TrimSpace(strings.Join(cmd.args[1:], " "))
+	args := database.SetFeedFollowTitleParams{
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+		Title:     nullString(title),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.SetFeedFollowTitle(ctx, args); err != nil {
+		return fmt.Errorf("unable to set feed title: %w", err)
+	}
+
+	if title == "" {
+		fmt.Printf("%s is shown by its own name again\n", feed.Url)
+	} else {
+		fmt.Printf("%s is now shown as %s\n", feed.Url, title)
+	}
+	return nil
+}
+
+// maxPriority keeps a priority within a year of recency either way.
+const maxPriority = 365
+
+// handlerPriority sets how far a followed feed's posts are moved up or down
+// browse and search: each point counts as one day, so a priority 2 post
+// from two days ago sorts with priority 0 posts from today.
+func handlerPriority(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("priority expects 2 arguments: feed_url priority")
+	}
+	ctx := context.Background()
+
+	priority, err := strconv.ParseInt(cmd.args[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("unable to parse priority: %w", err)
+	}
+	if priority < -maxPriority || priority > maxPriority {
+		return fmt.Errorf("priority must be between %d and %d, got %d", -maxPriority, maxPriority, priority)
+	}
+
+	feed, _, err := followedFeed(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	args := database.SetFeedFollowPriorityParams{
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+		Priority:  int32(priority),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.SetFeedFollowPriority(ctx, args); err != nil {
+		return fmt.Errorf("unable to set feed priority: %w", err)
+	}
+
+	fmt.Printf("%s now has priority %d, its posts sort as if %d day(s) newer\n", feed.Url, priority, priority)
+	return nil
+}
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/sanitize"
+)
+
+const (
+	greaderItemPrefix   = "tag:google.com,2005:reader/item/"
+	greaderReadingList  = "user/-/state/com.google/reading-list"
+	greaderRead         = "user/-/state/com.google/read"
+	greaderStarred      = "user/-/state/com.google/starred"
+	greaderAllLabel     = "user/-/label/All"
+	greaderLabelPrefix  = "user/-/label/"
+	greaderDefaultItems = 20
+	greaderMaxItems     = 1000
+)
+
+// errGReaderBadRequest marks errors caused by the client's request rather
+// than by gator, which are answered with a 400.
+var errGReaderBadRequest = errors.New("bad request")
+
+type greaderCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+type greaderSubscription struct {
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Categories []greaderCategory `json:"categories"`
+	Url        string            `json:"url"`
+	HtmlUrl    string            `json:"htmlUrl"`
+	IconUrl    string            `json:"iconUrl"`
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderContent struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type greaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl"`
+}
+
+type greaderItem struct {
+	ID            string         `json:"id"`
+	CrawlTimeMsec string         `json:"crawlTimeMsec"`
+	TimestampUsec string         `json:"timestampUsec"`
+	Published     int64          `json:"published"`
+	Updated       int64          `json:"updated"`
+	Title         string         `json:"title"`
+	Canonical     []greaderLink  `json:"canonical"`
+	Alternate     []greaderLink  `json:"alternate"`
+	Summary       greaderContent `json:"summary"`
+	Categories    []string       `json:"categories"`
+	Origin        greaderOrigin  `json:"origin"`
+}
+
+type greaderItemRef struct {
+	ID            string `json:"id"`
+	TimestampUsec string `json:"timestampUsec"`
+}
+
+// greaderStream is a stream id such as "feed/<url>", a label or the starred
+// state, resolved into the filters GetStreamItemsForUser understands.
+type greaderStream struct {
+	id          string
+	feedID      uuid.NullUUID
+	tag         sql.NullString
+	starredOnly bool
+}
+
+type greaderHandler func(w http.ResponseWriter, r *http.Request, user database.User) error
+
+func registerGReaderRoutes(s *state, mux *http.ServeMux) {
+	mux.HandleFunc("/greader/accounts/ClientLogin", func(w http.ResponseWriter, r *http.Request) {
+		if err := greaderClientLogin(s, w, r); err != nil {
+			s.logger.Error("greader login failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/greader/reader/api/0/token", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		token, _ := greaderAuthToken(r)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, token)
+		return nil
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/user-info", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		return writeJSON(w, map[string]string{
+			"userId":        user.ID.String(),
+			"userName":      user.Name,
+			"userProfileId": user.ID.String(),
+			"userEmail":     user.Name,
+		})
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/tag/list", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		tags := []greaderCategory{
+			{ID: greaderStarred},
+			{ID: greaderAllLabel, Label: "All", Type: "folder"},
+		}
+
+		//each of the user's tags is a folder
+		userTags, err := s.db.GetTagsForUser(r.Context(), user.ID)
+		if err != nil {
+			return fmt.Errorf("unable to get tags for user: %w", err)
+		}
+		for _, tag := range userTags {
+			tags = append(tags, greaderCategory{ID: greaderLabelPrefix + tag.Name, Label: tag.Name, Type: "folder"})
+		}
+		return writeJSON(w, map[string]any{"tags": tags})
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/subscription/list", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		feeds, err := s.db.GetFeedsForUser(r.Context(), user.ID)
+		if err != nil {
+			return fmt.Errorf("unable to get feeds for user: %w", err)
+		}
+
+		tags, err := feedTags(r.Context(), s, user)
+		if err != nil {
+			return err
+		}
+		titles, err := followTitles(r.Context(), s, user)
+		if err != nil {
+			return err
+		}
+
+		subscriptions := []greaderSubscription{}
+		for _, feed := range feeds {
+			categories := []greaderCategory{{ID: greaderAllLabel, Label: "All"}}
+			for _, tag := range tags[feed.ID] {
+				categories = append(categories, greaderCategory{ID: greaderLabelPrefix + tag, Label: tag})
+			}
+
+			subscriptions = append(subscriptions, greaderSubscription{
+				ID:         "feed/" + feed.Url,
+				Title:      titles[feed.ID],
+				Categories: categories,
+				Url:        feed.Url,
+				HtmlUrl:    feed.Url,
+			})
+		}
+		return writeJSON(w, map[string]any{"subscriptions": subscriptions})
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/stream/contents/{stream...}", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		streamID := r.PathValue("stream")
+		if streamID == "" {
+			streamID = r.FormValue("s")
+		}
+		stream, err := parseGReaderStream(r.Context(), s, streamID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		rows, continuation, err := greaderStreamItems(r.Context(), s, r, user, stream)
+		if err != nil {
+			return err
+		}
+
+		resp := map[string]any{
+			"id":      stream.id,
+			"updated": time.Now().Unix(),
+			"items":   greaderItems(rows),
+		}
+		if continuation != "" {
+			resp["continuation"] = continuation
+		}
+		return writeJSON(w, resp)
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/stream/items/ids", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		stream, err := parseGReaderStream(r.Context(), s, r.FormValue("s"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		rows, continuation, err := greaderStreamItems(r.Context(), s, r, user, stream)
+		if err != nil {
+			return err
+		}
+
+		refs := []greaderItemRef{}
+		for _, row := range rows {
+			refs = append(refs, greaderItemRef{
+				ID:            strconv.FormatInt(row.Seq, 10),
+				TimestampUsec: strconv.FormatInt(postTime(row.PublishedAt, row.CreatedAt).UnixMicro(), 10),
+			})
+		}
+
+		resp := map[string]any{"itemRefs": refs}
+		if continuation != "" {
+			resp["continuation"] = continuation
+		}
+		return writeJSON(w, resp)
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/stream/items/contents", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		seqs, err := parseGReaderItemIDs(r.Form["i"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		args := database.GetStreamItemsForUserBySeqsParams{
+			UserID: user.ID,
+			Seqs:   seqs,
+		}
+		found, err := s.db.GetStreamItemsForUserBySeqs(r.Context(), args)
+		if err != nil {
+			return fmt.Errorf("unable to get items by id: %w", err)
+		}
+
+		var rows []database.GetStreamItemsForUserRow
+		for _, row := range found {
+			rows = append(rows, database.GetStreamItemsForUserRow(row))
+		}
+
+		return writeJSON(w, map[string]any{
+			"id":      greaderReadingList,
+			"updated": time.Now().Unix(),
+			"items":   greaderItems(rows),
+		})
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/edit-tag", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		seqs, err := parseGReaderItemIDs(r.Form["i"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		//Look every item up first so an unknown one changes nothing
+		var posts []database.Post
+		for _, seq := range seqs {
+			args := database.GetPostForUserBySeqParams{
+				UserID: user.ID,
+				Seq:    seq,
+			}
+			post, err := s.db.GetPostForUserBySeq(r.Context(), args)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, fmt.Sprintf("no item %d among your subscriptions", seq), http.StatusNotFound)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("unable to get post by id: %w", err)
+			}
+			posts = append(posts, post)
+		}
+
+		for _, post := range posts {
+			for _, tag := range r.Form["a"] {
+				if err := greaderSetTag(r.Context(), s, user, post, tag, true); err != nil {
+					return err
+				}
+			}
+			for _, tag := range r.Form["r"] {
+				if err := greaderSetTag(r.Context(), s, user, post, tag, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, "OK")
+		return nil
+	}))
+
+	mux.HandleFunc("/greader/reader/api/0/mark-all-as-read", middlewareGReaderAuth(s, func(w http.ResponseWriter, r *http.Request, user database.User) error {
+		stream, err := parseGReaderStream(r.Context(), s, r.FormValue("s"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		before := time.Now()
+		if ts := r.FormValue("ts"); ts != "" {
+			usec, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				http.Error(w, "unable to parse ts", http.StatusBadRequest)
+				return nil
+			}
+			before = time.UnixMicro(usec)
+		}
+
+		now := time.Now()
+		switch {
+		case stream.feedID.Valid:
+			args := database.MarkFeedReadForUserParams{
+				Now:    now,
+				UserID: user.ID,
+				FeedID: stream.feedID.UUID,
+				Before: before,
+			}
+			err = s.db.MarkFeedReadForUser(r.Context(), args)
+		case stream.tag.Valid:
+			args := database.MarkTagReadForUserParams{
+				Now:    now,
+				UserID: user.ID,
+				Tag:    stream.tag.String,
+				Before: before,
+			}
+			err = s.db.MarkTagReadForUser(r.Context(), args)
+		default:
+			args := database.MarkAllReadForUserParams{
+				Now:    now,
+				UserID: user.ID,
+				Before: before,
+			}
+			err = s.db.MarkAllReadForUser(r.Context(), args)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to mark stream read: %w", err)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, "OK")
+		return nil
+	}))
+}
+
+func middlewareGReaderAuth(s *state, handler greaderHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := greaderAuthToken(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := s.db.GetUserByApiSession(r.Context(), token)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "unable to parse form", http.StatusBadRequest)
+			return
+		}
+
+		err = handler(w, r, user)
+		if errors.Is(err, errGReaderBadRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			s.logger.Error("greader request failed", "user", user.Name, "path", r.URL.Path, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func greaderAuthToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+}
+
+func greaderClientLogin(s *state, w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "unable to parse form", http.StatusBadRequest)
+		return nil
+	}
+
+	user, err := s.db.GetUser(r.Context(), r.FormValue("Email"))
+	if err != nil {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return nil
+	}
+
+	credentials, err := s.db.GetApiCredentialsForUser(r.Context(), user.ID)
+	if err != nil || !checkPassword(credentials.PasswordHash, r.FormValue("Passwd")) {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("unable to generate token: %w", err)
+	}
+
+	now := time.Now()
+	args := database.CreateApiSessionParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Token:     user.Name + "/" + hex.EncodeToString(raw),
+	}
+	session, err := s.db.CreateApiSession(r.Context(), args)
+	if err != nil {
+		return fmt.Errorf("unable to create api session: %w", err)
+	}
+
+	if r.FormValue("output") == "json" {
+		return writeJSON(w, map[string]string{
+			"SID":  session.Token,
+			"LSID": "null",
+			"Auth": session.Token,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%s\nLSID=null\nAuth=%s\n", session.Token, session.Token)
+	return nil
+}
+
+func parseGReaderStream(ctx context.Context, s *state, streamID string) (greaderStream, error) {
+	streamID = normalizeGReaderID(streamID)
+	stream := greaderStream{id: streamID}
+	switch {
+	case streamID == "" || streamID == greaderReadingList || streamID == greaderAllLabel:
+		stream.id = greaderReadingList
+	case streamID == greaderStarred:
+		stream.starredOnly = true
+	case strings.HasPrefix(streamID, "feed/"):
+		feed, err := s.db.GetFeedByUrl(ctx, strings.TrimPrefix(streamID, "feed/"))
+		if err != nil {
+			return greaderStream{}, fmt.Errorf("unknown feed: %s", streamID)
+		}
+		stream.feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	case strings.HasPrefix(streamID, greaderLabelPrefix):
+		stream.tag = nullString(normalizeTag(strings.TrimPrefix(streamID, greaderLabelPrefix)))
+	default:
+		return greaderStream{}, fmt.Errorf("unsupported stream: %s", streamID)
+	}
+
+	return stream, nil
+}
+
+// greaderStreamItems pages through a stream using the post seq as the
+// continuation token, newest first unless the client asks for r=o.
+func greaderStreamItems(ctx context.Context, s *state, r *http.Request, user database.User, stream greaderStream) ([]database.GetStreamItemsForUserRow, string, error) {
+	limit := greaderDefaultItems
+	if n := r.FormValue("n"); n != "" {
+		var err error
+		limit, err = strconv.Atoi(n)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: unable to parse n: %w", errGReaderBadRequest, err)
+		}
+	}
+	limit = min(max(limit, 1), greaderMaxItems)
+
+	var cursor int64
+	if c := r.FormValue("c"); c != "" {
+		var err error
+		cursor, err = strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: unable to parse continuation: %w", errGReaderBadRequest, err)
+		}
+	}
+
+	olderThan, err := greaderUnixParam(r, "ot")
+	if err != nil {
+		return nil, "", err
+	}
+	newerThan, err := greaderUnixParam(r, "nt")
+	if err != nil {
+		return nil, "", err
+	}
+	unreadOnly := r.FormValue("xt") == greaderRead
+
+	var rows []database.GetStreamItemsForUserRow
+	if r.FormValue("r") == "o" {
+		args := database.GetStreamItemsForUserOldestFirstParams{
+			UserID:      user.ID,
+			FeedID:      stream.feedID,
+			Tag:         stream.tag,
+			StarredOnly: stream.starredOnly,
+			UnreadOnly:  unreadOnly,
+			OlderThan:   olderThan,
+			NewerThan:   newerThan,
+			AfterSeq:    cursor,
+			MaxItems:    int32(limit),
+		}
+		found, err := s.db.GetStreamItemsForUserOldestFirst(ctx, args)
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to get stream items: %w", err)
+		}
+		for _, row := range found {
+			rows = append(rows, database.GetStreamItemsForUserRow(row))
+		}
+	} else {
+		if cursor == 0 {
+			cursor = 1<<63 - 1
+		}
+		args := database.GetStreamItemsForUserParams{
+			UserID:      user.ID,
+			FeedID:      stream.feedID,
+			Tag:         stream.tag,
+			StarredOnly: stream.starredOnly,
+			UnreadOnly:  unreadOnly,
+			OlderThan:   olderThan,
+			NewerThan:   newerThan,
+			BeforeSeq:   cursor,
+			MaxItems:    int32(limit),
+		}
+		rows, err = s.db.GetStreamItemsForUser(ctx, args)
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to get stream items: %w", err)
+		}
+	}
+
+	continuation := ""
+	if len(rows) == limit {
+		continuation = strconv.FormatInt(rows[len(rows)-1].Seq, 10)
+	}
+
+	return rows, continuation, nil
+}
+
+func greaderUnixParam(r *http.Request, name string) (sql.NullTime, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+
+	secs, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%w: unable to parse %s: %w", errGReaderBadRequest, name, err)
+	}
+
+	return sql.NullTime{Time: time.Unix(secs, 0), Valid: true}, nil
+}
+
+func greaderItems(rows []database.GetStreamItemsForUserRow) []greaderItem {
+	items := []greaderItem{}
+	for _, row := range rows {
+		published := postTime(row.PublishedAt, row.CreatedAt)
+
+		categories := []string{greaderReadingList, greaderAllLabel}
+		if row.Read {
+			categories = append(categories, greaderRead)
+		}
+		if row.Starred {
+			categories = append(categories, greaderStarred)
+		}
+
+		items = append(items, greaderItem{
+			ID:            fmt.Sprintf("%s%016x", greaderItemPrefix, row.Seq),
+			CrawlTimeMsec: strconv.FormatInt(row.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: strconv.FormatInt(published.UnixMicro(), 10),
+			Published:     published.Unix(),
+			Updated:       row.UpdatedAt.Unix(),
+			Title:         row.Title.String,
+			Canonical:     []greaderLink{{Href: row.Url}},
+			Alternate:     []greaderLink{{Href: row.Url, Type: "text/html"}},
+			Summary:       greaderContent{Direction: "ltr", Content: sanitize.HTML(row.Description.String)},
+			Categories:    categories,
+			Origin: greaderOrigin{
+				StreamID: "feed/" + row.FeedUrl,
+				Title:    row.FeedName,
+				HtmlUrl:  row.FeedUrl,
+			},
+		})
+	}
+
+	return items
+}
+
+func greaderSetTag(ctx context.Context, s *state, user database.User, post database.Post, tag string, add bool) error {
+	now := time.Now()
+
+	switch normalizeGReaderID(tag) {
+	case greaderRead:
+		args := database.SetPostReadParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			PostID:    post.ID,
+			Read:      add,
+		}
+		if err := s.db.SetPostRead(ctx, args); err != nil {
+			return fmt.Errorf("unable to set read: %w", err)
+		}
+	case greaderStarred:
+		args := database.SetPostStarredParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			PostID:    post.ID,
+			Starred:   add,
+		}
+		if err := s.db.SetPostStarred(ctx, args); err != nil {
+			return fmt.Errorf("unable to set starred: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// normalizeGReaderID rewrites "user/<id>/..." to "user/-/...", since clients
+// may send their own user id instead of "-".
+func normalizeGReaderID(id string) string {
+	if rest, ok := strings.CutPrefix(id, "user/"); ok {
+		if _, tail, found := strings.Cut(rest, "/"); found {
+			return "user/-/" + tail
+		}
+	}
+	return id
+}
+
+// parseGReaderItemIDs accepts both the long "tag:google.com,..." hex form
+// and the short decimal form of an item id.
+func parseGReaderItemIDs(ids []string) ([]int64, error) {
+	var seqs []int64
+	for _, id := range ids {
+		if hexID, ok := strings.CutPrefix(id, greaderItemPrefix); ok {
+			seq, err := strconv.ParseUint(hexID, 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse item id: %s", id)
+			}
+			seqs = append(seqs, int64(seq))
+			continue
+		}
+
+		seq, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse item id: %s", id)
+		}
+		seqs = append(seqs, seq)
+	}
+
+	return seqs, nil
+}
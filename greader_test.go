@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+type greaderFixture struct {
+	s      *state
+	server *httptest.Server
+	token  string
+	alice  database.User
+
+	post, bobPost database.Post
+}
+
+func newGReaderFixture(t *testing.T) *greaderFixture {
+	t.Helper()
+	s := newTestState(t)
+
+	alice := createTestUser(t, s, "alice")
+	if err := saveApiPassword(context.Background(), s, alice, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	bob := createTestUser(t, s, "bob")
+
+	f := &greaderFixture{s: s, alice: alice}
+	gators := createTestFeed(t, s, alice, "Gators", "https://gators.example/feed")
+	f.post = createTestPost(t, s, gators, "https://gators.example/0", time.Now())
+	bobs := createTestFeed(t, s, bob, "Bob's", "https://bob.example/feed")
+	f.bobPost = createTestPost(t, s, bobs, "https://bob.example/0", time.Now())
+
+	mux := http.NewServeMux()
+	registerGReaderRoutes(s, mux)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	resp, err := http.PostForm(f.server.URL+"/greader/accounts/ClientLogin", url.Values{
+		"Email":  {"alice"},
+		"Passwd": {"secret"},
+		"output": {"json"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login status %d: %s", resp.StatusCode, body)
+	}
+	_, rest, _ := strings.Cut(string(body), `"Auth":"`)
+	f.token, _, _ = strings.Cut(rest, `"`)
+
+	return f
+}
+
+// send posts form to path as alice and returns the response status.
+func (f *greaderFixture) send(t *testing.T, path string, form url.Values) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, f.server.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "GoogleLogin auth="+f.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// starred reports whether alice has starred post.
+func (f *greaderFixture) starred(t *testing.T, post database.Post) bool {
+	t.Helper()
+	var starred bool
+	err := f.s.conn.QueryRow("SELECT starred FROM post_states WHERE user_id = $1 AND post_id = $2", f.alice.ID, post.ID).Scan(&starred)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return starred
+}
+
+func TestGReaderEditTag(t *testing.T) {
+	f := newGReaderFixture(t)
+	own := strconv.FormatInt(f.post.Seq, 10)
+
+	if status := f.send(t, "/greader/reader/api/0/edit-tag", url.Values{"i": {own}, "a": {greaderStarred}}); status != http.StatusOK {
+		t.Fatalf("status %d starring alice's post", status)
+	}
+	if !f.starred(t, f.post) {
+		t.Error("alice's post isn't starred")
+	}
+
+	for _, ids := range [][]string{
+		{strconv.FormatInt(f.bobPost.Seq, 10)},
+		{"999999"},
+		//one unknown item leaves the others alone too
+		{own, strconv.FormatInt(f.bobPost.Seq, 10)},
+	} {
+		status := f.send(t, "/greader/reader/api/0/edit-tag", url.Values{"i": ids, "r": {greaderStarred}})
+		if status != http.StatusNotFound {
+			t.Errorf("%v: status %d, want %d", ids, status, http.StatusNotFound)
+		}
+	}
+	if !f.starred(t, f.post) {
+		t.Error("a rejected edit unstarred alice's post")
+	}
+	if f.starred(t, f.bobPost) {
+		t.Error("a post outside alice's follows was starred")
+	}
+}
+
+func TestGReaderStreamBadParams(t *testing.T) {
+	f := newGReaderFixture(t)
+
+	for _, param := range []string{"n", "c", "ot", "nt"} {
+		for _, path := range []string{"/greader/reader/api/0/stream/contents/", "/greader/reader/api/0/stream/items/ids"} {
+			status := f.send(t, path, url.Values{param: {"abc"}})
+			if status != http.StatusBadRequest {
+				t.Errorf("%s with %s=abc: status %d, want %d", path, param, status, http.StatusBadRequest)
+			}
+		}
+	}
+
+	if status := f.send(t, "/greader/reader/api/0/stream/items/ids", url.Values{"n": {"5"}}); status != http.StatusOK {
+		t.Errorf("status %d for a valid request", status)
+	}
+}
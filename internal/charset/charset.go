@@ -0,0 +1,106 @@
+// Package charset converts feed bodies to UTF-8 before they reach the XML
+// decoder, which only understands UTF-8 on its own.
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// sniffLen is how much of the body is inspected for a byte order mark or an
+// XML declaration.
+const sniffLen = 1024
+
+var declEncoding = regexp.MustCompile(`encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// NewReader returns a reader that decodes r to UTF-8. A byte order mark wins,
+// then the charset parameter of contentType, then the encoding named in the
+// XML declaration, falling back to UTF-8.
+func NewReader(r io.Reader, contentType string) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+
+	label, bomLen := fromBOM(head)
+	if label != "" {
+		//the XML decoder does not expect a byte order mark
+		br.Discard(bomLen)
+	} else if label = fromContentType(contentType); label == "" {
+		label = fromDeclaration(head)
+	}
+
+	return Convert(br, label)
+}
+
+// Convert decodes r from the named charset to UTF-8. Labels are resolved as
+// browsers do, so "latin1" and "iso-8859-1" both decode as Windows-1252.
+func Convert(r io.Reader, label string) (io.Reader, error) {
+	if label == "" {
+		return r, nil
+	}
+
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset: %s", label)
+	}
+	if enc == unicode.UTF8 {
+		return r, nil
+	}
+
+	return transform.NewReader(r, enc.NewDecoder()), nil
+}
+
+// Passthrough is an xml.Decoder CharsetReader for input that has already
+// been converted by NewReader, whose XML declaration may still name the
+// original charset.
+func Passthrough(label string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
+
+func fromBOM(head []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8", 3
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return "utf-16be", 2
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return "utf-16le", 2
+	}
+	return "", 0
+}
+
+func fromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+func fromDeclaration(head []byte) string {
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if !bytes.HasPrefix(head, []byte("<?xml")) {
+		return ""
+	}
+	end := bytes.Index(head, []byte("?>"))
+	if end < 0 {
+		return ""
+	}
+
+	m := declEncoding.FindSubmatch(head[:end])
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
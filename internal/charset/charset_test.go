@@ -0,0 +1,162 @@
+package charset
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type fixtureFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title string `xml:"title"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// decodeTitles runs a fixture through NewReader the way openFeed does and
+// returns the channel title followed by the item titles.
+func decodeTitles(t *testing.T, name, contentType string) []string {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+
+	var feed fixtureFeed
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = Passthrough
+	if err := decoder.Decode(&feed); err != nil {
+		t.Fatalf("unable to decode %s: %v", name, err)
+	}
+
+	titles := []string{feed.Channel.Title}
+	for _, item := range feed.Channel.Items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func TestNewReaderFixtures(t *testing.T) {
+	russian := []string{"Привет, мир", "Привет, мир", "Новости о крокодилах"}
+	german := []string{"Grüße aus dem Sumpf", "Grüße aus dem Sumpf", "鰐 🐊"}
+
+	tests := []struct {
+		name        string
+		file        string
+		contentType string
+		want        []string
+	}{
+		{
+			name: "iso-8859-1 from declaration",
+			file: "iso-8859-1.xml",
+			want: []string{"Café crème", "Café crème", "Über naïve façades"},
+		},
+		{
+			name:        "iso-8859-1 from content type",
+			file:        "iso-8859-1.xml",
+			contentType: "application/rss+xml; charset=ISO-8859-1",
+			want:        []string{"Café crème", "Café crème", "Über naïve façades"},
+		},
+		{
+			name: "windows-1252 from declaration",
+			file: "windows-1252.xml",
+			want: []string{"“Smart” quotes – €5", "“Smart” quotes – €5", "Ellipsis… and ‰"},
+		},
+		{
+			name:        "windows-1252 labelled latin1",
+			file:        "windows-1252.xml",
+			contentType: "text/xml; charset=latin1",
+			want:        []string{"“Smart” quotes – €5", "“Smart” quotes – €5", "Ellipsis… and ‰"},
+		},
+		{
+			name: "shift_jis from declaration",
+			file: "shift_jis.xml",
+			want: []string{"日本語のフィード", "日本語のフィード", "ワニのニュース"},
+		},
+		{
+			name: "koi8-r from declaration",
+			file: "koi8-r.xml",
+			want: russian,
+		},
+		{
+			name: "utf-16le with a bom",
+			file: "utf-16le-bom.xml",
+			want: german,
+		},
+		{
+			name: "utf-16be with a bom",
+			file: "utf-16be-bom.xml",
+			want: german,
+		},
+		{
+			name:        "content type wins over the declaration",
+			file:        "windows-1251-declared-koi8-r.xml",
+			contentType: "application/rss+xml; charset=windows-1251",
+			want:        russian,
+		},
+		{
+			name:        "declaration is used when the content type has no charset",
+			file:        "koi8-r.xml",
+			contentType: "application/rss+xml",
+			want:        russian,
+		},
+		{
+			name:        "bom wins over the content type",
+			file:        "utf-16le-bom.xml",
+			contentType: "application/rss+xml; charset=ISO-8859-1",
+			want:        german,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeTitles(t, tt.file, tt.contentType)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("titles = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReaderWrongDeclaration(t *testing.T) {
+	//Without a content type the declaration is trusted, even when it's wrong
+	got := decodeTitles(t, "windows-1251-declared-koi8-r.xml", "")
+	if got[0] == "Привет, мир" {
+		t.Errorf("expected the koi8-r declaration to be used, got %q", got[0])
+	}
+}
+
+func TestNewReaderUnsupported(t *testing.T) {
+	_, err := NewReader(strings.NewReader("<rss/>"), "text/xml; charset=x-no-such-charset")
+	if err == nil {
+		t.Fatal("expected an error for an unknown charset")
+	}
+}
+
+func TestNewReaderUTF8(t *testing.T) {
+	body := "\xEF\xBB\xBF<?xml version=\"1.0\"?><rss/>"
+	r, err := NewReader(strings.NewReader(body), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body[3:] {
+		t.Errorf("got %q, want the body without its bom", got)
+	}
+}
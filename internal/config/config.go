@@ -12,6 +12,20 @@ const configFileName = ".gatorconfig.json"
 type Config struct {
 	DbUrl           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	LogLevel        string `json:"log_level,omitempty"`
+	LogFormat       string `json:"log_format,omitempty"`
+	SMTPHost        string `json:"smtp_host,omitempty"`
+	SMTPPort        int    `json:"smtp_port,omitempty"`
+	SMTPUsername    string `json:"smtp_username,omitempty"`
+	SMTPPassword    string `json:"smtp_password,omitempty"`
+	DigestFrom      string `json:"digest_from,omitempty"`
+	MaxFeedBytes    int64  `json:"max_feed_bytes,omitempty"`
+	MinPollInterval string `json:"min_poll_interval,omitempty"`
+	MaxPollInterval string `json:"max_poll_interval,omitempty"`
+	PublicURL       string `json:"public_url,omitempty"`
+
+	RetentionMaxAge   string `json:"retention_max_age,omitempty"`
+	RetentionMaxPosts int    `json:"retention_max_posts,omitempty"`
 }
 
 func (c *Config) SetUser(userName string) error {
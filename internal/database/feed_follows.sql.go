@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,10 +23,10 @@ WITH inserted_feed_follow as (
         $4,
         $5
     )
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, title, priority
 )
 SELECT 
-inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
+inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.title, inserted_feed_follow.priority,
 feeds.name as feed_name,
 users.name as user_name
 FROM inserted_feed_follow
@@ -47,6 +48,8 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Title     sql.NullString
+	Priority  int32
 	FeedName  string
 	UserName  string
 }
@@ -66,6 +69,8 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.Title,
+		&i.Priority,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -87,15 +92,42 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const getFeedFollowForUser = `-- name: GetFeedFollowForUser :one
+SELECT id, created_at, updated_at, user_id, feed_id, title, priority
+FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowForUserParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollowForUser(ctx context.Context, arg GetFeedFollowForUserParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollowForUser, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.Title,
+		&i.Priority,
+	)
+	return i, err
+}
+
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 SELECT 
-feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
-feeds.name as feed_name,
+feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.title, feed_follows.priority,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
 users.name as user_name
 FROM feed_follows
 INNER JOIN feeds on feeds.id = feed_follows.feed_id
 INNER JOIN users on users.id = feed_follows.user_id
 WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.priority DESC, feed_name
 `
 
 type GetFeedFollowsForUserRow struct {
@@ -104,6 +136,8 @@ type GetFeedFollowsForUserRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Title     sql.NullString
+	Priority  int32
 	FeedName  string
 	UserName  string
 }
@@ -123,6 +157,8 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Title,
+			&i.Priority,
 			&i.FeedName,
 			&i.UserName,
 		); err != nil {
@@ -141,8 +177,8 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 
 const getFeedFollowsForUserByName = `-- name: GetFeedFollowsForUserByName :many
 SELECT 
-feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
-feeds.name as feed_name,
+feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.title, feed_follows.priority,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
 users.name as user_name
 FROM feed_follows
 INNER JOIN feeds on feeds.id = feed_follows.feed_id
@@ -155,6 +191,8 @@ type GetFeedFollowsForUserByNameRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Title     sql.NullString
+	Priority  int32
 	FeedName  string
 	UserName  string
 }
@@ -174,6 +212,8 @@ func (q *Queries) GetFeedFollowsForUserByName(ctx context.Context, name string)
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Title,
+			&i.Priority,
 			&i.FeedName,
 			&i.UserName,
 		); err != nil {
@@ -189,3 +229,94 @@ func (q *Queries) GetFeedFollowsForUserByName(ctx context.Context, name string)
 	}
 	return items, nil
 }
+
+const mergeFeedFollowPreferences = `-- name: MergeFeedFollowPreferences :exec
+UPDATE feed_follows
+SET title = COALESCE(feed_follows.title, old.title),
+    priority = CASE WHEN feed_follows.priority = 0 THEN old.priority ELSE feed_follows.priority END,
+    updated_at = $1
+FROM feed_follows old
+WHERE feed_follows.feed_id = $2
+AND old.feed_id = $3
+AND old.user_id = feed_follows.user_id
+`
+
+type MergeFeedFollowPreferencesParams struct {
+	UpdatedAt  time.Time
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+// For users following both feeds, the follow of to_feed_id keeps its own
+// title and priority when it has them and takes those of from_feed_id's
+// follow otherwise
+func (q *Queries) MergeFeedFollowPreferences(ctx context.Context, arg MergeFeedFollowPreferencesParams) error {
+	_, err := q.db.ExecContext(ctx, mergeFeedFollowPreferences, arg.UpdatedAt, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $1, updated_at = $2
+WHERE feed_follows.feed_id = $3
+AND feed_follows.user_id NOT IN (
+    SELECT existing.user_id FROM feed_follows existing WHERE existing.feed_id = $1
+)
+`
+
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	UpdatedAt  time.Time
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.UpdatedAt, arg.FromFeedID)
+	return err
+}
+
+const setFeedFollowPriority = `-- name: SetFeedFollowPriority :exec
+UPDATE feed_follows
+SET priority = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type SetFeedFollowPriorityParams struct {
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Priority  int32
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedFollowPriority(ctx context.Context, arg SetFeedFollowPriorityParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFollowPriority,
+		arg.UserID,
+		arg.FeedID,
+		arg.Priority,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setFeedFollowTitle = `-- name: SetFeedFollowTitle :exec
+UPDATE feed_follows
+SET title = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type SetFeedFollowTitleParams struct {
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Title     sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedFollowTitle(ctx context.Context, arg SetFeedFollowTitleParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFollowTitle,
+		arg.UserID,
+		arg.FeedID,
+		arg.Title,
+		arg.UpdatedAt,
+	)
+	return err
+}
@@ -23,7 +23,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, seq, next_fetch_at, poll_interval_seconds, poll_interval_override_seconds, hub_url, self_url, fetch_full_article, retention_max_age_seconds, retention_max_posts, items_seen_at, last_succeeded_at, fetch_failures
 `
 
 type CreateFeedParams struct {
@@ -53,15 +53,72 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+		&i.PollIntervalOverrideSeconds,
+		&i.HubUrl,
+		&i.SelfUrl,
+		&i.FetchFullArticle,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.ItemsSeenAt,
+		&i.LastSucceededAt,
+		&i.FetchFailures,
+	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const getFeed = `-- name: GetFeed :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq, next_fetch_at, poll_interval_seconds, poll_interval_override_seconds, hub_url, self_url, fetch_full_article, retention_max_age_seconds, retention_max_posts, items_seen_at, last_succeeded_at, fetch_failures
+FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeed, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Seq,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+		&i.PollIntervalOverrideSeconds,
+		&i.HubUrl,
+		&i.SelfUrl,
+		&i.FetchFullArticle,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.ItemsSeenAt,
+		&i.LastSucceededAt,
+		&i.FetchFailures,
 	)
 	return i, err
 }
 
 const getFeedByUrl = `-- name: GetFeedByUrl :one
 SELECT 
-id, created_at, updated_at, name, url, user_id, last_fetched_at
+id, created_at, updated_at, name, url, user_id, last_fetched_at, seq, next_fetch_at, poll_interval_seconds, poll_interval_override_seconds, hub_url, self_url, fetch_full_article, retention_max_age_seconds, retention_max_posts, items_seen_at, last_succeeded_at, fetch_failures
 FROM feeds
-WHERE url = $1
+WHERE feeds.url = $1
+OR feeds.id IN (SELECT feed_aliases.feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+ORDER BY feeds.url = $1 DESC
 LIMIT 1
 `
 
@@ -76,12 +133,149 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+		&i.PollIntervalOverrideSeconds,
+		&i.HubUrl,
+		&i.SelfUrl,
+		&i.FetchFullArticle,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.ItemsSeenAt,
+		&i.LastSucceededAt,
+		&i.FetchFailures,
+	)
+	return i, err
+}
+
+const getFeedForUserBySeq = `-- name: GetFeedForUserBySeq :one
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.seq, feeds.next_fetch_at, feeds.poll_interval_seconds, feeds.poll_interval_override_seconds, feeds.hub_url, feeds.self_url, feeds.fetch_full_article, feeds.retention_max_age_seconds, feeds.retention_max_posts, feeds.items_seen_at, feeds.last_succeeded_at, feeds.fetch_failures
+FROM feeds
+INNER JOIN feed_follows on feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND feeds.seq = $2
+`
+
+type GetFeedForUserBySeqParams struct {
+	UserID uuid.UUID
+	Seq    int64
+}
+
+func (q *Queries) GetFeedForUserBySeq(ctx context.Context, arg GetFeedForUserBySeqParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedForUserBySeq, arg.UserID, arg.Seq)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Seq,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+		&i.PollIntervalOverrideSeconds,
+		&i.HubUrl,
+		&i.SelfUrl,
+		&i.FetchFullArticle,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.ItemsSeenAt,
+		&i.LastSucceededAt,
+		&i.FetchFailures,
 	)
 	return i, err
 }
 
+const getFeedListForUser = `-- name: GetFeedListForUser :many
+SELECT
+feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.seq, feeds.next_fetch_at, feeds.poll_interval_seconds, feeds.poll_interval_override_seconds, feeds.hub_url, feeds.self_url, feeds.fetch_full_article, feeds.retention_max_age_seconds, feeds.retention_max_posts, feeds.items_seen_at, feeds.last_succeeded_at, feeds.fetch_failures,
+COALESCE(feed_follows.title, feeds.name)::text as title,
+feed_follows.priority,
+COUNT(posts.id) FILTER (WHERE NOT COALESCE(post_states.read, FALSE))::bigint as unread
+FROM feed_follows
+INNER JOIN feeds on feeds.id = feed_follows.feed_id
+LEFT JOIN posts on posts.feed_id = feeds.id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+GROUP BY feeds.id, feed_follows.id
+ORDER BY feed_follows.priority DESC, COALESCE(feed_follows.title, feeds.name)
+`
+
+type GetFeedListForUserRow struct {
+	ID                          uuid.UUID
+	CreatedAt                   time.Time
+	UpdatedAt                   time.Time
+	Name                        string
+	Url                         string
+	UserID                      uuid.UUID
+	LastFetchedAt               sql.NullTime
+	Seq                         int64
+	NextFetchAt                 sql.NullTime
+	PollIntervalSeconds         sql.NullInt32
+	PollIntervalOverrideSeconds sql.NullInt32
+	HubUrl                      sql.NullString
+	SelfUrl                     sql.NullString
+	FetchFullArticle            bool
+	RetentionMaxAgeSeconds      sql.NullInt32
+	RetentionMaxPosts           sql.NullInt32
+	ItemsSeenAt                 sql.NullTime
+	LastSucceededAt             sql.NullTime
+	FetchFailures               int32
+	Title                       string
+	Priority                    int32
+	Unread                      int64
+}
+
+func (q *Queries) GetFeedListForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedListForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedListForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedListForUserRow
+	for rows.Next() {
+		var i GetFeedListForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Seq,
+			&i.NextFetchAt,
+			&i.PollIntervalSeconds,
+			&i.PollIntervalOverrideSeconds,
+			&i.HubUrl,
+			&i.SelfUrl,
+			&i.FetchFullArticle,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.ItemsSeenAt,
+			&i.LastSucceededAt,
+			&i.FetchFailures,
+			&i.Title,
+			&i.Priority,
+			&i.Unread,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq, next_fetch_at, poll_interval_seconds, poll_interval_override_seconds, hub_url, self_url, fetch_full_article, retention_max_age_seconds, retention_max_posts, items_seen_at, last_succeeded_at, fetch_failures FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -101,6 +295,69 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Seq,
+			&i.NextFetchAt,
+			&i.PollIntervalSeconds,
+			&i.PollIntervalOverrideSeconds,
+			&i.HubUrl,
+			&i.SelfUrl,
+			&i.FetchFullArticle,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.ItemsSeenAt,
+			&i.LastSucceededAt,
+			&i.FetchFailures,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsForUser = `-- name: GetFeedsForUser :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.seq, feeds.next_fetch_at, feeds.poll_interval_seconds, feeds.poll_interval_override_seconds, feeds.hub_url, feeds.self_url, feeds.fetch_full_article, feeds.retention_max_age_seconds, feeds.retention_max_posts, feeds.items_seen_at, feeds.last_succeeded_at, feeds.fetch_failures
+FROM feeds
+INNER JOIN feed_follows on feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.seq
+`
+
+func (q *Queries) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Seq,
+			&i.NextFetchAt,
+			&i.PollIntervalSeconds,
+			&i.PollIntervalOverrideSeconds,
+			&i.HubUrl,
+			&i.SelfUrl,
+			&i.FetchFullArticle,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.ItemsSeenAt,
+			&i.LastSucceededAt,
+			&i.FetchFailures,
 		); err != nil {
 			return nil, err
 		}
@@ -116,14 +373,29 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, seq, next_fetch_at, poll_interval_seconds, poll_interval_override_seconds, hub_url, self_url, fetch_full_article, retention_max_age_seconds, retention_max_posts, items_seen_at, last_succeeded_at, fetch_failures
 FROM feeds
+WHERE (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+AND ($2::uuid IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follows
+    INNER JOIN feed_follow_tags on feed_follow_tags.feed_follow_id = feed_follows.id
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = $2::uuid
+    AND tags.name = $3::text
+))
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT 1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+type GetNextFeedToFetchParams struct {
+	Now       time.Time
+	TagUserID uuid.NullUUID
+	Tag       sql.NullString
+}
+
+func (q *Queries) GetNextFeedToFetch(ctx context.Context, arg GetNextFeedToFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, arg.Now, arg.TagUserID, arg.Tag)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -133,10 +405,36 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+		&i.PollIntervalOverrideSeconds,
+		&i.HubUrl,
+		&i.SelfUrl,
+		&i.FetchFullArticle,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.ItemsSeenAt,
+		&i.LastSucceededAt,
+		&i.FetchFailures,
 	)
 	return i, err
 }
 
+const getStalestFeedSuccess = `-- name: GetStalestFeedSuccess :one
+SELECT COALESCE(last_succeeded_at, created_at)::timestamp AS last_succeeded_at
+FROM feeds
+ORDER BY 1
+LIMIT 1
+`
+
+func (q *Queries) GetStalestFeedSuccess(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getStalestFeedSuccess)
+	var last_succeeded_at time.Time
+	err := row.Scan(&last_succeeded_at)
+	return last_succeeded_at, err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds 
 SET last_fetched_at = $2, updated_at = $3
@@ -153,3 +451,182 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.LastFetchedAt, arg.UpdatedAt)
 	return err
 }
+
+const markFeedSucceeded = `-- name: MarkFeedSucceeded :exec
+UPDATE feeds
+SET last_succeeded_at = $2, updated_at = $3
+WHERE id = $1
+`
+
+type MarkFeedSucceededParams struct {
+	ID              uuid.UUID
+	LastSucceededAt sql.NullTime
+	UpdatedAt       time.Time
+}
+
+func (q *Queries) MarkFeedSucceeded(ctx context.Context, arg MarkFeedSucceededParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedSucceeded, arg.ID, arg.LastSucceededAt, arg.UpdatedAt)
+	return err
+}
+
+const setFeedFullArticle = `-- name: SetFeedFullArticle :exec
+UPDATE feeds
+SET fetch_full_article = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedFullArticleParams struct {
+	ID               uuid.UUID
+	FetchFullArticle bool
+	UpdatedAt        time.Time
+}
+
+func (q *Queries) SetFeedFullArticle(ctx context.Context, arg SetFeedFullArticleParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedFullArticle, arg.ID, arg.FetchFullArticle, arg.UpdatedAt)
+	return err
+}
+
+const setFeedHub = `-- name: SetFeedHub :exec
+UPDATE feeds
+SET hub_url = $2, self_url = $3, updated_at = $4
+WHERE id = $1
+`
+
+type SetFeedHubParams struct {
+	ID        uuid.UUID
+	HubUrl    sql.NullString
+	SelfUrl   sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedHub(ctx context.Context, arg SetFeedHubParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedHub,
+		arg.ID,
+		arg.HubUrl,
+		arg.SelfUrl,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setFeedItemsSeen = `-- name: SetFeedItemsSeen :exec
+UPDATE feeds
+SET items_seen_at = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedItemsSeenParams struct {
+	ID          uuid.UUID
+	ItemsSeenAt sql.NullTime
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) SetFeedItemsSeen(ctx context.Context, arg SetFeedItemsSeenParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedItemsSeen, arg.ID, arg.ItemsSeenAt, arg.UpdatedAt)
+	return err
+}
+
+const setFeedPollOverride = `-- name: SetFeedPollOverride :exec
+UPDATE feeds
+SET poll_interval_override_seconds = $1::integer,
+    next_fetch_at = CASE
+        WHEN $1::integer IS NULL THEN NULL
+        WHEN last_fetched_at IS NULL THEN next_fetch_at
+        ELSE last_fetched_at + $1::integer * interval '1 second'
+    END,
+    updated_at = $2
+WHERE id = $3
+`
+
+type SetFeedPollOverrideParams struct {
+	PollIntervalOverrideSeconds sql.NullInt32
+	UpdatedAt                   time.Time
+	ID                          uuid.UUID
+}
+
+// A new override counts from the last fetch, and clearing one makes the feed
+// due so its interval is learned again
+func (q *Queries) SetFeedPollOverride(ctx context.Context, arg SetFeedPollOverrideParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedPollOverride, arg.PollIntervalOverrideSeconds, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const setFeedRetention = `-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_age_seconds = $2, retention_max_posts = $3, updated_at = $4
+WHERE id = $1
+`
+
+type SetFeedRetentionParams struct {
+	ID                     uuid.UUID
+	RetentionMaxAgeSeconds sql.NullInt32
+	RetentionMaxPosts      sql.NullInt32
+	UpdatedAt              time.Time
+}
+
+func (q *Queries) SetFeedRetention(ctx context.Context, arg SetFeedRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRetention,
+		arg.ID,
+		arg.RetentionMaxAgeSeconds,
+		arg.RetentionMaxPosts,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setFeedRetry = `-- name: SetFeedRetry :exec
+UPDATE feeds
+SET next_fetch_at = $2, fetch_failures = fetch_failures + 1, updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedRetryParams struct {
+	ID          uuid.UUID
+	NextFetchAt sql.NullTime
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) SetFeedRetry(ctx context.Context, arg SetFeedRetryParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRetry, arg.ID, arg.NextFetchAt, arg.UpdatedAt)
+	return err
+}
+
+const setFeedSchedule = `-- name: SetFeedSchedule :exec
+UPDATE feeds
+SET next_fetch_at = $2, poll_interval_seconds = $3, fetch_failures = 0, updated_at = $4
+WHERE id = $1
+`
+
+type SetFeedScheduleParams struct {
+	ID                  uuid.UUID
+	NextFetchAt         sql.NullTime
+	PollIntervalSeconds sql.NullInt32
+	UpdatedAt           time.Time
+}
+
+func (q *Queries) SetFeedSchedule(ctx context.Context, arg SetFeedScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSchedule,
+		arg.ID,
+		arg.NextFetchAt,
+		arg.PollIntervalSeconds,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :exec
+UPDATE feeds
+SET url = $2, updated_at = $3
+WHERE id = $1
+`
+
+type UpdateFeedUrlParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedUrl, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
@@ -11,14 +11,74 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiCredential struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	PasswordHash string
+	FeverKey     string
+}
+
+type ApiSession struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Token     string
+}
+
+type Digest struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Email      string
+	Frequency  string
+	LastSentAt sql.NullTime
+}
+
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                          uuid.UUID
+	CreatedAt                   time.Time
+	UpdatedAt                   time.Time
+	Name                        string
+	Url                         string
+	UserID                      uuid.UUID
+	LastFetchedAt               sql.NullTime
+	Seq                         int64
+	NextFetchAt                 sql.NullTime
+	PollIntervalSeconds         sql.NullInt32
+	PollIntervalOverrideSeconds sql.NullInt32
+	HubUrl                      sql.NullString
+	SelfUrl                     sql.NullString
+	FetchFullArticle            bool
+	RetentionMaxAgeSeconds      sql.NullInt32
+	RetentionMaxPosts           sql.NullInt32
+	ItemsSeenAt                 sql.NullTime
+	LastSucceededAt             sql.NullTime
+	FetchFailures               int32
+}
+
+type FeedAlias struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	FeedID    uuid.UUID
+	Url       string
 }
 
 type FeedFollow struct {
@@ -27,17 +87,81 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Title     sql.NullString
+	Priority  int32
+}
+
+type FeedFollowTag struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	FeedFollowID uuid.UUID
+	TagID        uuid.UUID
+}
+
+type FeedToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Token     string
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       sql.NullString
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+}
+
+type PostCategory struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	PostID    uuid.UUID
+	Name      string
+}
+
+type PostState struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Read      bool
+	Starred   bool
+}
+
+type Rule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Action    string
+	Kind      string
+	Pattern   string
+	FeedID    uuid.NullUUID
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Seq       int64
 }
 
 type User struct {
@@ -46,3 +170,39 @@ type User struct {
 	UpdatedAt time.Time
 	Name      string
 }
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	FeedID    uuid.NullUUID
+	Secret    string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	WebhookID      uuid.UUID
+	PostID         uuid.UUID
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebsubSubscription struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         uuid.UUID
+	HubUrl         string
+	TopicUrl       string
+	Secret         string
+	Status         string
+	LeaseExpiresAt sql.NullTime
+}
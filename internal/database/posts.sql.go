@@ -11,10 +11,25 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countPostsForUser = `-- name: CountPostsForUser :one
+SELECT COUNT(*)
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+`
+
+func (q *Queries) CountPostsForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, author, comments_url, last_seen_at)
 VALUES (
     $1,
     $2,
@@ -23,9 +38,13 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11,
+    $2
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, seq, content, author, comments_url, article, article_fetched_at, last_seen_at, article_queued_at
 `
 
 type CreatePostParams struct {
@@ -37,6 +56,9 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Content     sql.NullString
+	Author      sql.NullString
+	CommentsUrl sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -49,6 +71,9 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Content,
+		arg.Author,
+		arg.CommentsUrl,
 	)
 	var i Post
 	err := row.Scan(
@@ -60,26 +85,520 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Seq,
+		&i.Content,
+		&i.Author,
+		&i.CommentsUrl,
+		&i.Article,
+		&i.ArticleFetchedAt,
+		&i.LastSeenAt,
+		&i.ArticleQueuedAt,
 	)
 	return i, err
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id
+const deleteUnstarredPosts = `-- name: DeleteUnstarredPosts :execrows
+DELETE FROM posts
+WHERE posts.id = ANY($1::uuid[])
+AND NOT EXISTS (
+    SELECT 1 FROM post_states
+    WHERE post_states.post_id = posts.id AND post_states.starred
+)
+`
+
+func (q *Queries) DeleteUnstarredPosts(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUnstarredPosts, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getItemsForUserBefore = `-- name: GetItemsForUserBefore :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+feeds.seq as feed_seq,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq < $2
+ORDER BY posts.seq DESC
+LIMIT $3
+`
+
+type GetItemsForUserBeforeParams struct {
+	UserID uuid.UUID
+	Seq    int64
+	Limit  int32
+}
+
+type GetItemsForUserBeforeRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedSeq          int64
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetItemsForUserBefore(ctx context.Context, arg GetItemsForUserBeforeParams) ([]GetItemsForUserBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getItemsForUserBefore, arg.UserID, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetItemsForUserBeforeRow
+	for rows.Next() {
+		var i GetItemsForUserBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedSeq,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getItemsForUserBySeqs = `-- name: GetItemsForUserBySeqs :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+feeds.seq as feed_seq,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq = ANY($2::bigint[])
+ORDER BY posts.seq ASC
+`
+
+type GetItemsForUserBySeqsParams struct {
+	UserID uuid.UUID
+	Seqs   []int64
+}
+
+type GetItemsForUserBySeqsRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedSeq          int64
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetItemsForUserBySeqs(ctx context.Context, arg GetItemsForUserBySeqsParams) ([]GetItemsForUserBySeqsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getItemsForUserBySeqs, arg.UserID, pq.Array(arg.Seqs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetItemsForUserBySeqsRow
+	for rows.Next() {
+		var i GetItemsForUserBySeqsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedSeq,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getItemsForUserSince = `-- name: GetItemsForUserSince :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+feeds.seq as feed_seq,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq > $2
+ORDER BY posts.seq ASC
+LIMIT $3
+`
+
+type GetItemsForUserSinceParams struct {
+	UserID uuid.UUID
+	Seq    int64
+	Limit  int32
+}
+
+type GetItemsForUserSinceRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedSeq          int64
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetItemsForUserSince(ctx context.Context, arg GetItemsForUserSinceParams) ([]GetItemsForUserSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getItemsForUserSince, arg.UserID, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetItemsForUserSinceRow
+	for rows.Next() {
+		var i GetItemsForUserSinceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedSeq,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostCadenceForFeed = `-- name: GetPostCadenceForFeed :one
+SELECT
+COUNT(*) AS post_count,
+COALESCE(MIN(recent.published_at), 'epoch')::timestamp AS oldest,
+COALESCE(MAX(recent.published_at), 'epoch')::timestamp AS newest
+FROM (
+    SELECT posts.published_at
+    FROM posts
+    WHERE posts.feed_id = $1 AND posts.published_at IS NOT NULL
+    ORDER BY posts.published_at DESC
+    LIMIT $2
+) AS recent
+`
+
+type GetPostCadenceForFeedParams struct {
+	FeedID     uuid.UUID
+	SampleSize int32
+}
+
+type GetPostCadenceForFeedRow struct {
+	PostCount int64
+	Oldest    time.Time
+	Newest    time.Time
+}
+
+func (q *Queries) GetPostCadenceForFeed(ctx context.Context, arg GetPostCadenceForFeedParams) (GetPostCadenceForFeedRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostCadenceForFeed, arg.FeedID, arg.SampleSize)
+	var i GetPostCadenceForFeedRow
+	err := row.Scan(&i.PostCount, &i.Oldest, &i.Newest)
+	return i, err
+}
+
+const getPostForUserBySeq = `-- name: GetPostForUserBySeq :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND posts.seq = $2
+`
+
+type GetPostForUserBySeqParams struct {
+	UserID uuid.UUID
+	Seq    int64
+}
+
+func (q *Queries) GetPostForUserBySeq(ctx context.Context, arg GetPostForUserBySeqParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUserBySeq, arg.UserID, arg.Seq)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Seq,
+		&i.Content,
+		&i.Author,
+		&i.CommentsUrl,
+		&i.Article,
+		&i.ArticleFetchedAt,
+		&i.LastSeenAt,
+		&i.ArticleQueuedAt,
+	)
+	return i, err
+}
+
+const getPostForUserByUrl = `-- name: GetPostForUserByUrl :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND posts.url = $2
+`
+
+type GetPostForUserByUrlParams struct {
+	UserID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) GetPostForUserByUrl(ctx context.Context, arg GetPostForUserByUrlParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUserByUrl, arg.UserID, arg.Url)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Seq,
+		&i.Content,
+		&i.Author,
+		&i.CommentsUrl,
+		&i.Article,
+		&i.ArticleFetchedAt,
+		&i.LastSeenAt,
+		&i.ArticleQueuedAt,
+	)
+	return i, err
+}
+
+const getPostListForUser = `-- name: GetPostListForUser :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
 FROM posts
 INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
 WHERE feed_follows.user_id = $1
+AND ($3::uuid IS NULL OR posts.feed_id = $3::uuid)
 ORDER BY posts.published_at DESC NULLS LAST
 LIMIT $2
 `
 
-type GetPostsForUserParams struct {
+type GetPostListForUserParams struct {
 	UserID uuid.UUID
 	Limit  int32
+	FeedID uuid.NullUUID
+}
+
+type GetPostListForUserRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedName         string
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetPostListForUser(ctx context.Context, arg GetPostListForUserParams) ([]GetPostListForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostListForUser, arg.UserID, arg.Limit, arg.FeedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostListForUserRow
+	for rows.Next() {
+		var i GetPostListForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedName,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+AND ($4::text IS NULL OR posts.author ILIKE '%' || $4::text || '%')
+AND ($5::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    WHERE post_categories.post_id = posts.id AND LOWER(post_categories.name) = LOWER($5::text)
+))
+AND ($6::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = $6::text
+))
+ORDER BY posts.published_at + feed_follows.priority * interval '1 day' DESC NULLS LAST, posts.seq DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetPostsForUserParams struct {
+	UserID   uuid.UUID
+	Limit    int32
+	Offset   int32
+	Author   sql.NullString
+	Category sql.NullString
+	Tag      sql.NullString
 }
 
+// Each point of priority counts as a day of recency, see handlerPriority
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.Limit,
+		arg.Offset,
+		arg.Author,
+		arg.Category,
+		arg.Tag,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +615,14 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -109,3 +636,745 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getPrunablePosts = `-- name: GetPrunablePosts :many
+WITH ranked AS (
+    SELECT
+    posts.id,
+    posts.feed_id,
+    posts.last_seen_at,
+    COALESCE(posts.published_at, posts.created_at) AS posted_at,
+    ROW_NUMBER() OVER (
+        PARTITION BY posts.feed_id
+        ORDER BY posts.published_at DESC NULLS LAST, posts.seq DESC
+    ) AS position
+    FROM posts
+)
+SELECT
+ranked.id,
+ranked.feed_id,
+feeds.name as feed_name
+FROM ranked
+INNER JOIN feeds on feeds.id = ranked.feed_id
+WHERE feeds.items_seen_at IS NOT NULL
+AND (ranked.last_seen_at IS NULL OR ranked.last_seen_at < feeds.items_seen_at)
+AND NOT EXISTS (
+    SELECT 1 FROM post_states
+    WHERE post_states.post_id = ranked.id AND post_states.starred
+)
+AND (
+    (
+        COALESCE(feeds.retention_max_age_seconds, $1::integer) > 0
+        AND ranked.posted_at < $2::timestamp - make_interval(secs => COALESCE(feeds.retention_max_age_seconds, $1::integer))
+    )
+    OR (
+        COALESCE(feeds.retention_max_posts, $3::integer) > 0
+        AND ranked.position > COALESCE(feeds.retention_max_posts, $3::integer)
+    )
+)
+ORDER BY feeds.name, ranked.feed_id
+`
+
+type GetPrunablePostsParams struct {
+	MaxAgeSeconds int32
+	Now           time.Time
+	MaxPosts      int32
+}
+
+type GetPrunablePostsRow struct {
+	ID       uuid.UUID
+	FeedID   uuid.UUID
+	FeedName string
+}
+
+func (q *Queries) GetPrunablePosts(ctx context.Context, arg GetPrunablePostsParams) ([]GetPrunablePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPrunablePosts, arg.MaxAgeSeconds, arg.Now, arg.MaxPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPrunablePostsRow
+	for rows.Next() {
+		var i GetPrunablePostsRow
+		if err := rows.Scan(&i.ID, &i.FeedID, &i.FeedName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQueuedArticles = `-- name: GetQueuedArticles :many
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, seq, content, author, comments_url, article, article_fetched_at, last_seen_at, article_queued_at
+FROM posts
+WHERE article_queued_at IS NOT NULL AND article_fetched_at IS NULL
+ORDER BY article_queued_at
+LIMIT $1
+`
+
+func (q *Queries) GetQueuedArticles(ctx context.Context, limit int32) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getQueuedArticles, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStarredPostSeqsForUser = `-- name: GetStarredPostSeqsForUser :many
+SELECT posts.seq
+FROM posts
+INNER JOIN post_states on post_states.post_id = posts.id
+WHERE post_states.user_id = $1 AND post_states.starred
+ORDER BY posts.seq
+`
+
+func (q *Queries) GetStarredPostSeqsForUser(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getStarredPostSeqsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStreamItemsForUser = `-- name: GetStreamItemsForUser :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+feeds.seq as feed_seq,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND ($2::uuid IS NULL OR posts.feed_id = $2::uuid)
+AND ($3::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = $3::text
+))
+AND (NOT $4::boolean OR COALESCE(post_states.starred, FALSE))
+AND (NOT $5::boolean OR NOT COALESCE(post_states.read, FALSE))
+AND ($6::timestamp IS NULL OR posts.created_at < $6::timestamp)
+AND ($7::timestamp IS NULL OR posts.created_at > $7::timestamp)
+AND posts.seq < $8
+ORDER BY posts.seq DESC
+LIMIT $9
+`
+
+type GetStreamItemsForUserParams struct {
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	Tag         sql.NullString
+	StarredOnly bool
+	UnreadOnly  bool
+	OlderThan   sql.NullTime
+	NewerThan   sql.NullTime
+	BeforeSeq   int64
+	MaxItems    int32
+}
+
+type GetStreamItemsForUserRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedSeq          int64
+	FeedName         string
+	FeedUrl          string
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetStreamItemsForUser(ctx context.Context, arg GetStreamItemsForUserParams) ([]GetStreamItemsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getStreamItemsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.Tag,
+		arg.StarredOnly,
+		arg.UnreadOnly,
+		arg.OlderThan,
+		arg.NewerThan,
+		arg.BeforeSeq,
+		arg.MaxItems,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStreamItemsForUserRow
+	for rows.Next() {
+		var i GetStreamItemsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedSeq,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStreamItemsForUserBySeqs = `-- name: GetStreamItemsForUserBySeqs :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+feeds.seq as feed_seq,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq = ANY($2::bigint[])
+ORDER BY posts.seq DESC
+`
+
+type GetStreamItemsForUserBySeqsParams struct {
+	UserID uuid.UUID
+	Seqs   []int64
+}
+
+type GetStreamItemsForUserBySeqsRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedSeq          int64
+	FeedName         string
+	FeedUrl          string
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetStreamItemsForUserBySeqs(ctx context.Context, arg GetStreamItemsForUserBySeqsParams) ([]GetStreamItemsForUserBySeqsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getStreamItemsForUserBySeqs, arg.UserID, pq.Array(arg.Seqs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStreamItemsForUserBySeqsRow
+	for rows.Next() {
+		var i GetStreamItemsForUserBySeqsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedSeq,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStreamItemsForUserOldestFirst = `-- name: GetStreamItemsForUserOldestFirst :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+feeds.seq as feed_seq,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND ($2::uuid IS NULL OR posts.feed_id = $2::uuid)
+AND ($3::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = $3::text
+))
+AND (NOT $4::boolean OR COALESCE(post_states.starred, FALSE))
+AND (NOT $5::boolean OR NOT COALESCE(post_states.read, FALSE))
+AND ($6::timestamp IS NULL OR posts.created_at < $6::timestamp)
+AND ($7::timestamp IS NULL OR posts.created_at > $7::timestamp)
+AND posts.seq > $8
+ORDER BY posts.seq ASC
+LIMIT $9
+`
+
+type GetStreamItemsForUserOldestFirstParams struct {
+	UserID      uuid.UUID
+	FeedID      uuid.NullUUID
+	Tag         sql.NullString
+	StarredOnly bool
+	UnreadOnly  bool
+	OlderThan   sql.NullTime
+	NewerThan   sql.NullTime
+	AfterSeq    int64
+	MaxItems    int32
+}
+
+type GetStreamItemsForUserOldestFirstRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedSeq          int64
+	FeedName         string
+	FeedUrl          string
+	Read             bool
+	Starred          bool
+}
+
+func (q *Queries) GetStreamItemsForUserOldestFirst(ctx context.Context, arg GetStreamItemsForUserOldestFirstParams) ([]GetStreamItemsForUserOldestFirstRow, error) {
+	rows, err := q.db.QueryContext(ctx, getStreamItemsForUserOldestFirst,
+		arg.UserID,
+		arg.FeedID,
+		arg.Tag,
+		arg.StarredOnly,
+		arg.UnreadOnly,
+		arg.OlderThan,
+		arg.NewerThan,
+		arg.AfterSeq,
+		arg.MaxItems,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStreamItemsForUserOldestFirstRow
+	for rows.Next() {
+		var i GetStreamItemsForUserOldestFirstRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedSeq,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.Read,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTimelineForUser = `-- name: GetTimelineForUser :many
+SELECT
+posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY posts.published_at DESC NULLS LAST
+LIMIT $2
+`
+
+type GetTimelineForUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+type GetTimelineForUserRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            sql.NullString
+	Url              string
+	Description      sql.NullString
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Seq              int64
+	Content          sql.NullString
+	Author           sql.NullString
+	CommentsUrl      sql.NullString
+	Article          sql.NullString
+	ArticleFetchedAt sql.NullTime
+	LastSeenAt       sql.NullTime
+	ArticleQueuedAt  sql.NullTime
+	FeedName         string
+	FeedUrl          string
+}
+
+func (q *Queries) GetTimelineForUser(ctx context.Context, arg GetTimelineForUserParams) ([]GetTimelineForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTimelineForUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTimelineForUserRow
+	for rows.Next() {
+		var i GetTimelineForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+			&i.FeedName,
+			&i.FeedUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadPostSeqsForUser = `-- name: GetUnreadPostSeqsForUser :many
+SELECT posts.seq
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND NOT COALESCE(post_states.read, FALSE)
+ORDER BY posts.seq
+`
+
+func (q *Queries) GetUnreadPostSeqsForUser(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadPostSeqsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markPostSeen = `-- name: MarkPostSeen :exec
+UPDATE posts
+SET last_seen_at = $2::timestamp
+WHERE url = $1
+`
+
+type MarkPostSeenParams struct {
+	Url    string
+	SeenAt time.Time
+}
+
+func (q *Queries) MarkPostSeen(ctx context.Context, arg MarkPostSeenParams) error {
+	_, err := q.db.ExecContext(ctx, markPostSeen, arg.Url, arg.SeenAt)
+	return err
+}
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = $1, updated_at = $2
+WHERE feed_id = $3
+`
+
+type MovePostsParams struct {
+	ToFeedID   uuid.UUID
+	UpdatedAt  time.Time
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.ToFeedID, arg.UpdatedAt, arg.FromFeedID)
+	return err
+}
+
+const queuePostArticle = `-- name: QueuePostArticle :exec
+UPDATE posts
+SET article_queued_at = $2::timestamp, updated_at = $2::timestamp
+WHERE id = $1
+`
+
+type QueuePostArticleParams struct {
+	ID       uuid.UUID
+	QueuedAt time.Time
+}
+
+func (q *Queries) QueuePostArticle(ctx context.Context, arg QueuePostArticleParams) error {
+	_, err := q.db.ExecContext(ctx, queuePostArticle, arg.ID, arg.QueuedAt)
+	return err
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, posts.content, posts.author, posts.comments_url, posts.article, posts.article_fetched_at, posts.last_seen_at, posts.article_queued_at
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+AND (
+    posts.title ILIKE '%' || $4::text || '%'
+    OR posts.description ILIKE '%' || $4::text || '%'
+    OR posts.content ILIKE '%' || $4::text || '%'
+)
+AND ($5::text IS NULL OR posts.author ILIKE '%' || $5::text || '%')
+AND ($6::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    WHERE post_categories.post_id = posts.id AND LOWER(post_categories.name) = LOWER($6::text)
+))
+AND ($7::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = $7::text
+))
+ORDER BY posts.published_at + feed_follows.priority * interval '1 day' DESC NULLS LAST, posts.seq DESC
+LIMIT $2 OFFSET $3
+`
+
+type SearchPostsForUserParams struct {
+	UserID   uuid.UUID
+	Limit    int32
+	Offset   int32
+	Query    string
+	Author   sql.NullString
+	Category sql.NullString
+	Tag      sql.NullString
+}
+
+// Ordered the same way as GetPostsForUser
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.UserID,
+		arg.Limit,
+		arg.Offset,
+		arg.Query,
+		arg.Author,
+		arg.Category,
+		arg.Tag,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Article,
+			&i.ArticleFetchedAt,
+			&i.LastSeenAt,
+			&i.ArticleQueuedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setPostArticle = `-- name: SetPostArticle :exec
+UPDATE posts
+SET article = $2, article_fetched_at = $3::timestamp, updated_at = $3::timestamp
+WHERE id = $1
+`
+
+type SetPostArticleParams struct {
+	ID        uuid.UUID
+	Article   sql.NullString
+	FetchedAt time.Time
+}
+
+func (q *Queries) SetPostArticle(ctx context.Context, arg SetPostArticleParams) error {
+	_, err := q.db.ExecContext(ctx, setPostArticle, arg.ID, arg.Article, arg.FetchedAt)
+	return err
+}
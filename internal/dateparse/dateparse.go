@@ -0,0 +1,280 @@
+// Package dateparse parses the many date formats found in real-world feeds:
+// RFC 822/1123 with numeric or named zones, RFC 3339 and ISO 8601 with or
+// without a zone, one- or two-digit days, missing seconds, two-digit years
+// and month names in several European languages.
+package dateparse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// layouts are tried in order against the normalized value, which has no
+// weekday, no commas, English month abbreviations and numeric zones.
+var layouts = []string{
+	//ISO 8601 / RFC 3339
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04 -0700",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+
+	//RFC 822 / RFC 1123, day first
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006 3:04 PM",
+	"2 Jan 2006",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 06 15:04:05",
+	"2 Jan 06",
+
+	//month first
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04 -0700",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 15:04",
+	"Jan 2 2006 3:04 PM",
+	"Jan 2 2006",
+	"Jan 2 15:04:05 -0700 2006",
+	"Jan 2 15:04:05 2006",
+}
+
+// Parse returns the time described by value, assuming UTC when it has no zone.
+func Parse(value string) (time.Time, error) {
+	normalized := normalize(value)
+	if normalized == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date: %q", value)
+}
+
+func normalize(value string) string {
+	value = stripComments(strings.TrimSpace(value))
+
+	//ISO dates are already in a form the layouts understand
+	if len(value) >= 10 && value[4] == '-' && isDigits(value[:4]) {
+		value = strings.Replace(value, "t", "T", 1)
+		if strings.HasSuffix(value, "z") {
+			value = strings.TrimSuffix(value, "z") + "Z"
+		}
+		fields := strings.Fields(value)
+		if len(fields) > 0 {
+			if offset, ok := zoneOffset(fields[len(fields)-1]); ok {
+				fields[len(fields)-1] = offset
+			}
+		}
+		return strings.Join(fields, " ")
+	}
+
+	fields := strings.Fields(value)
+	var out []string
+	for i, field := range fields {
+		followedByComma := strings.HasSuffix(field, ",")
+		field = strings.Trim(field, ",.")
+		lower := strings.ToLower(field)
+
+		//"5 de marzo de 2024", "the 5th of March"
+		if fillers[lower] {
+			continue
+		}
+
+		if i == 0 && weekdays[lower] {
+			//"mar" is both Tuesday and March, so only drop it when it reads like a weekday
+			if _, isMonth := months[lower]; !isMonth || followedByComma {
+				continue
+			}
+		}
+
+		if month, ok := months[lower]; ok {
+			out = append(out, month)
+			continue
+		}
+
+		if offset, ok := zoneOffset(field); ok {
+			out = append(out, offset)
+			continue
+		}
+
+		out = append(out, stripOrdinal(field))
+	}
+
+	return strings.Join(out, " ")
+}
+
+// stripComments drops trailing parenthesised comments, as in
+// "+0000 (UTC)", which RFC 822 allows after the zone.
+func stripComments(value string) string {
+	for strings.HasSuffix(value, ")") {
+		open := strings.LastIndex(value, "(")
+		if open < 0 {
+			break
+		}
+		value = strings.TrimSpace(value[:open])
+	}
+	return value
+}
+
+// zoneOffset turns a zone abbreviation such as "EST" or "GMT" into a
+// numeric offset.
+func zoneOffset(field string) (string, bool) {
+	minutes, ok := zones[strings.ToUpper(field)]
+	if !ok {
+		return "", false
+	}
+
+	sign := '+'
+	if minutes < 0 {
+		sign = '-'
+		minutes = -minutes
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, minutes/60, minutes%60), true
+}
+
+// stripOrdinal turns "1st", "2nd", "3rd" and "4th" into plain numbers.
+func stripOrdinal(field string) string {
+	lower := strings.ToLower(field)
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if trimmed, ok := strings.CutSuffix(lower, suffix); ok && trimmed != "" && isDigits(trimmed) {
+			return trimmed
+		}
+	}
+	return field
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+var fillers = map[string]bool{
+	"de":  true,
+	"del": true,
+	"of":  true,
+	"the": true,
+	"at":  true,
+	"à":   true,
+	"um":  true,
+}
+
+var months = map[string]string{}
+
+func init() {
+	names := map[string][]string{
+		"Jan": {"jan", "january", "janv", "janvier", "januar", "jän", "jänner", "ene", "enero", "gen", "gennaio", "janeiro", "januari", "sty", "styczeń"},
+		"Feb": {"feb", "february", "févr", "fév", "fevr", "février", "fevrier", "februar", "febrero", "febbraio", "fev", "fevereiro", "februari", "lut", "luty"},
+		"Mar": {"mar", "march", "mars", "mär", "märz", "maerz", "mrz", "marzo", "março", "marco", "mrt", "maart", "marzec"},
+		"Apr": {"apr", "april", "avr", "avril", "abr", "abril", "aprile", "kwi", "kwiecień"},
+		"May": {"may", "mai", "mayo", "mag", "maggio", "maio", "mei", "maj"},
+		"Jun": {"jun", "june", "juin", "juni", "junio", "giu", "giugno", "junho", "cze", "czerwiec"},
+		"Jul": {"jul", "july", "juil", "juillet", "juli", "julio", "lug", "luglio", "julho", "lip", "lipiec"},
+		"Aug": {"aug", "august", "août", "aout", "ago", "agosto", "augustus", "sie", "sierpień"},
+		"Sep": {"sep", "sept", "september", "septembre", "septiembre", "set", "settembre", "setembro", "wrz", "wrzesień"},
+		"Oct": {"oct", "october", "octobre", "okt", "oktober", "octubre", "ott", "ottobre", "out", "outubro", "paź", "październik"},
+		"Nov": {"nov", "november", "novembre", "noviembre", "novembro", "lis", "listopad"},
+		"Dec": {"dec", "december", "déc", "décembre", "decembre", "dez", "dezember", "dic", "diciembre", "dicembre", "dezembro", "gru", "grudzień"},
+	}
+	for english, variants := range names {
+		for _, variant := range variants {
+			months[variant] = english
+		}
+	}
+}
+
+var weekdays = map[string]bool{}
+
+func init() {
+	for _, day := range []string{
+		//English
+		"mon", "tue", "tues", "wed", "thu", "thur", "thurs", "fri", "sat", "sun",
+		"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+		//French
+		"lun", "mar", "mer", "jeu", "ven", "sam", "dim",
+		"lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi", "dimanche",
+		//German
+		"mo", "di", "mi", "do", "fr", "sa", "so",
+		"montag", "dienstag", "mittwoch", "donnerstag", "freitag", "samstag", "sonntag",
+		//Spanish
+		"mié", "mie", "jue", "vie", "sáb", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado", "domingo",
+		//Italian
+		"gio", "sab", "dom", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato", "domenica",
+		//Portuguese
+		"seg", "ter", "qua", "qui", "sex", "segunda", "terça", "quarta", "quinta", "sexta",
+		//Dutch
+		"ma", "wo", "vr", "za", "zo", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag", "zondag",
+	} {
+		weekdays[day] = true
+	}
+}
+
+// zones maps common abbreviations to their offset from UTC in minutes.
+var zones = map[string]int{
+	"UT":   0,
+	"UTC":  0,
+	"GMT":  0,
+	"Z":    0,
+	"WET":  0,
+	"WEST": 60,
+	"BST":  60,
+	"IST":  330,
+	"CET":  60,
+	"CEST": 120,
+	"MET":  60,
+	"MEST": 120,
+	"EET":  120,
+	"EEST": 180,
+	"MSK":  180,
+	"EST":  -5 * 60,
+	"EDT":  -4 * 60,
+	"CST":  -6 * 60,
+	"CDT":  -5 * 60,
+	"MST":  -7 * 60,
+	"MDT":  -6 * 60,
+	"PST":  -8 * 60,
+	"PDT":  -7 * 60,
+	"AKST": -9 * 60,
+	"AKDT": -8 * 60,
+	"HST":  -10 * 60,
+	"AST":  -4 * 60,
+	"ADT":  -3 * 60,
+	"NST":  -210,
+	"NDT":  -150,
+	"SGT":  8 * 60,
+	"HKT":  8 * 60,
+	"AWST": 8 * 60,
+	"JST":  9 * 60,
+	"KST":  9 * 60,
+	"ACST": 570,
+	"ACDT": 630,
+	"AEST": 10 * 60,
+	"AEDT": 11 * 60,
+	"NZST": 12 * 60,
+	"NZDT": 13 * 60,
+}
@@ -0,0 +1,125 @@
+package dateparse
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"rfc1123", "Tue, 05 Mar 2024 10:00:00 GMT", "2024-03-05T10:00:00Z"},
+		{"iso with named zone", "2024-03-05T10:00:00 UTC", "2024-03-05T10:00:00Z"},
+		{"zone comment", "Tue, 05 Mar 2024 10:00:00 +0000 (UTC)", "2024-03-05T10:00:00Z"},
+		{"spaces around", "  Tue, 05 Mar 2024 10:00:00 +0000  ", "2024-03-05T10:00:00Z"},
+		{"ordinal day", "March 5th, 2024", "2024-03-05T00:00:00Z"},
+		{"two digit year", "5 Mar 99", "1999-03-05T00:00:00Z"},
+		{"mar as weekday", "mar., 5 mars 2024", "2024-03-05T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.value, err)
+			}
+			if got.Format(time.RFC3339Nano) != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.value, got.Format(time.RFC3339Nano), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"   ",
+		"(UTC)",
+		"yesterday",
+		"2024-13-05",
+		"32 Mar 2024",
+		"Tue, 05 Mar 2024 25:00:00 GMT",
+	} {
+		if got, err := Parse(value); err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+// TestParseFixtures checks every date in testdata/dates.txt, one tab
+// separated input and expected RFC 3339 time per line.
+func TestParseFixtures(t *testing.T) {
+	for _, fixture := range readFixtures(t) {
+		got, err := Parse(fixture.value)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", fixture.value, err)
+			continue
+		}
+		if got.Format(time.RFC3339Nano) != fixture.want {
+			t.Errorf("Parse(%q) = %s, want %s", fixture.value, got.Format(time.RFC3339Nano), fixture.want)
+		}
+	}
+}
+
+// FuzzParse checks that Parse never panics and that whatever it accepts
+// survives a round trip through RFC 3339.
+func FuzzParse(f *testing.F) {
+	for _, fixture := range readFixtures(f) {
+		f.Add(fixture.value)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		got, err := Parse(value)
+		if err != nil {
+			return
+		}
+
+		formatted := got.Format(time.RFC3339Nano)
+		again, err := Parse(formatted)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %s, which doesn't parse again: %v", value, formatted, err)
+		}
+		if !again.Equal(got) {
+			t.Fatalf("Parse(%q) = %s, but that parses as %s", value, formatted, again.Format(time.RFC3339Nano))
+		}
+	})
+}
+
+type fixture struct {
+	value string
+	want  string
+}
+
+func readFixtures(tb testing.TB) []fixture {
+	tb.Helper()
+
+	file, err := os.Open("testdata/dates.txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+
+	var fixtures []fixture
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		value, want, ok := strings.Cut(line, "\t")
+		if !ok {
+			tb.Fatalf("fixture without a tab: %q", line)
+		}
+		fixtures = append(fixtures, fixture{value: value, want: want})
+	}
+	if err := scanner.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	return fixtures
+}
@@ -0,0 +1,373 @@
+// Package readability pulls the main article out of a web page, leaving
+// behind navigation, sidebars, comments and the rest of the page chrome.
+//
+// It follows the approach of Arc90's Readability: paragraphs score points
+// for their parents by length and commas, class names and ids nudge those
+// scores up or down, link-heavy blocks are penalised, and the best scoring
+// element is taken together with any siblings that look like they belong
+// to it.
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoArticle is returned for pages without anything that looks like an
+// article.
+var ErrNoArticle = errors.New("no article found")
+
+// minArticleLen is how much text a page needs for its best candidate to be
+// taken as the article.
+const minArticleLen = 250
+
+var (
+	unlikely = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|menu|meta|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tags|tool|widget|^ad-|-ad$|\bads?\b`)
+	likely   = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positive = regexp.MustCompile(`(?i)article|blog|body|content|entry|h-entry|hentry|main|page|post|story|text`)
+	negative = regexp.MustCompile(`(?i)byline|comment|contact|footer|footnote|hidden|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|social|sponsor|taboola|widget|\bads?\b`)
+)
+
+// removed elements never hold article text.
+var removed = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Nav:      true,
+	atom.Aside:    true,
+	atom.Footer:   true,
+	atom.Svg:      true,
+	atom.Link:     true,
+	atom.Meta:     true,
+}
+
+// blockTags are the elements that stop a <div> from counting as a
+// paragraph of its own.
+var blockTags = map[atom.Atom]bool{
+	atom.Blockquote: true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Figure:     true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Table:      true,
+	atom.Ul:         true,
+	atom.Article:    true,
+}
+
+// Article is what Extract found.
+type Article struct {
+	Title string
+	// Content is the article's HTML, with links and images made absolute.
+	// It is not sanitized.
+	Content string
+	// Text is the article's plain text.
+	Text string
+}
+
+// Extract reads an HTML page served from pageURL and returns its article.
+func Extract(r io.Reader, pageURL *url.URL) (Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Article{}, err
+	}
+
+	title := pageTitle(doc)
+	prune(doc)
+
+	scores := map[*html.Node]float64{}
+	for n := range doc.Descendants() {
+		if !isParagraph(n) {
+			continue
+		}
+		text := innerText(n)
+		if len(text) < 25 {
+			continue
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		parent := n.Parent
+		for level := 0; parent != nil && parent.Type == html.ElementNode && level < 3; level++ {
+			if _, ok := scores[parent]; !ok {
+				scores[parent] = initialScore(parent)
+			}
+			switch level {
+			case 0:
+				scores[parent] += score
+			case 1:
+				scores[parent] += score / 2
+			default:
+				scores[parent] += score / 6
+			}
+			parent = parent.Parent
+		}
+	}
+
+	var top *html.Node
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		scores[n] = score
+		if top == nil || score > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		return Article{}, ErrNoArticle
+	}
+
+	nodes := withSiblings(top, scores)
+
+	var content bytes.Buffer
+	var text strings.Builder
+	for _, n := range nodes {
+		absolutize(n, pageURL)
+		if err := html.Render(&content, n); err != nil {
+			return Article{}, err
+		}
+		text.WriteString(innerText(n))
+		text.WriteString("\n\n")
+	}
+
+	plain := strings.TrimSpace(text.String())
+	if len(plain) < minArticleLen {
+		return Article{}, ErrNoArticle
+	}
+
+	return Article{Title: title, Content: content.String(), Text: plain}, nil
+}
+
+// pageTitle prefers og:title, which leaves out the site name, to <title>.
+func pageTitle(doc *html.Node) string {
+	title := ""
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.Meta:
+			if attr(n, "property") == "og:title" && strings.TrimSpace(attr(n, "content")) != "" {
+				return strings.TrimSpace(attr(n, "content"))
+			}
+		case atom.Title:
+			if title == "" {
+				title = strings.TrimSpace(innerText(n))
+			}
+		}
+	}
+	return title
+}
+
+// prune takes out everything that can't be part of the article so it
+// neither scores nor ends up in the result.
+func prune(doc *html.Node) {
+	var doomed []*html.Node
+	for n := range doc.Descendants() {
+		if n.Type == html.CommentNode {
+			doomed = append(doomed, n)
+			continue
+		}
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if removed[n.DataAtom] || hasAttr(n, "hidden") || attr(n, "aria-hidden") == "true" {
+			doomed = append(doomed, n)
+			continue
+		}
+
+		switch n.DataAtom {
+		case atom.Html, atom.Body, atom.Article, atom.Main, atom.A:
+			continue
+		}
+		names := attr(n, "class") + " " + attr(n, "id")
+		if unlikely.MatchString(names) && !likely.MatchString(names) {
+			doomed = append(doomed, n)
+		}
+	}
+
+	for _, n := range doomed {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+func isParagraph(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		return true
+	case atom.Div:
+		//a div of bare text is a paragraph in all but name
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && blockTags[c.DataAtom] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func initialScore(n *html.Node) float64 {
+	score := classWeight(n)
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score += 10
+	case atom.Div:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+	return score
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, name := range []string{attr(n, "class"), attr(n, "id")} {
+		if name == "" {
+			continue
+		}
+		if negative.MatchString(name) {
+			weight -= 25
+		}
+		if positive.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the share of n's text that sits inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(innerText(n))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	for c := range n.Descendants() {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += len(innerText(c))
+		}
+	}
+	return min(float64(linked)/float64(total), 1)
+}
+
+// withSiblings returns top along with the siblings that score close to it
+// or read like a paragraph of the same article.
+func withSiblings(top *html.Node, scores map[*html.Node]float64) []*html.Node {
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+
+	threshold := max(10, scores[top]*0.2)
+	topClass := attr(top, "class")
+
+	var nodes []*html.Node
+	for n := top.Parent.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n == top {
+			nodes = append(nodes, n)
+			continue
+		}
+
+		bonus := 0.0
+		if topClass != "" && attr(n, "class") == topClass {
+			bonus = scores[top] * 0.2
+		}
+		if score, ok := scores[n]; ok && score+bonus >= threshold {
+			nodes = append(nodes, n)
+			continue
+		}
+
+		if n.DataAtom == atom.P {
+			text := innerText(n)
+			density := linkDensity(n)
+			switch {
+			case len(text) > 80 && density < 0.25:
+				nodes = append(nodes, n)
+			case len(text) > 0 && len(text) <= 80 && density == 0 && strings.ContainsAny(text, ".!?"):
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// absolutize resolves links and images against the page, picking up lazily
+// loaded images from their data attributes on the way.
+func absolutize(root *html.Node, pageURL *url.URL) {
+	for n := range root.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n.DataAtom == atom.Img && attr(n, "src") == "" {
+			for _, lazy := range []string{"data-src", "data-original", "data-lazy-src"} {
+				if src := attr(n, lazy); src != "" {
+					n.Attr = append(n.Attr, html.Attribute{Key: "src", Val: src})
+					break
+				}
+			}
+		}
+
+		for i, a := range n.Attr {
+			if a.Key != "href" && a.Key != "src" {
+				continue
+			}
+			if ref, err := url.Parse(strings.TrimSpace(a.Val)); err == nil && pageURL != nil {
+				n.Attr[i].Val = pageURL.ResolveReference(ref).String()
+			}
+		}
+	}
+}
+
+func innerText(n *html.Node) string {
+	var b strings.Builder
+	for c := range n.Descendants() {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Mute      = "mute"
+	Highlight = "highlight"
+)
+
+const (
+	// Keyword matches a case-insensitive substring.
+	Keyword = "keyword"
+	// Regex matches a regular expression.
+	Regex = "regex"
+	// Feed matches every post of the rule's feed.
+	Feed = "feed"
+)
+
+// Rule is a compiled mute or highlight rule. When FeedID is set the rule
+// only applies to posts from that feed.
+type Rule struct {
+	Action  string
+	Kind    string
+	Pattern string
+	FeedID  uuid.NullUUID
+
+	re *regexp.Regexp
+}
+
+func Compile(action, kind, pattern string, feedID uuid.NullUUID) (Rule, error) {
+	if action != Mute && action != Highlight {
+		return Rule{}, fmt.Errorf("unknown rule action: %s", action)
+	}
+
+	rule := Rule{
+		Action:  action,
+		Kind:    kind,
+		Pattern: pattern,
+		FeedID:  feedID,
+	}
+
+	switch kind {
+	case Keyword:
+		if pattern == "" {
+			return Rule{}, fmt.Errorf("keyword rule needs a keyword")
+		}
+	case Regex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("unable to compile regex: %w", err)
+		}
+		rule.re = re
+	case Feed:
+		if !feedID.Valid {
+			return Rule{}, fmt.Errorf("feed rule needs a feed")
+		}
+	default:
+		return Rule{}, fmt.Errorf("unknown rule kind: %s", kind)
+	}
+
+	return rule, nil
+}
+
+// Match reports whether the rule applies to a post's title and description.
+func (r Rule) Match(feedID uuid.UUID, title, description string) bool {
+	if r.FeedID.Valid && r.FeedID.UUID != feedID {
+		return false
+	}
+
+	switch r.Kind {
+	case Keyword:
+		keyword := strings.ToLower(r.Pattern)
+		return strings.Contains(strings.ToLower(title), keyword) ||
+			strings.Contains(strings.ToLower(description), keyword)
+	case Regex:
+		return r.re.MatchString(title) || r.re.MatchString(description)
+	case Feed:
+		return true
+	}
+
+	return false
+}
+
+// Evaluate runs every rule against a post. Muting wins over highlighting,
+// so a muted post is never reported as highlighted.
+func Evaluate(rules []Rule, feedID uuid.UUID, title, description string) (muted, highlighted bool) {
+	for _, rule := range rules {
+		if !rule.Match(feedID, title, description) {
+			continue
+		}
+		switch rule.Action {
+		case Mute:
+			return true, false
+		case Highlight:
+			highlighted = true
+		}
+	}
+
+	return false, highlighted
+}
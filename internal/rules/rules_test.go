@@ -0,0 +1,126 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	gators = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	swamps = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+)
+
+func compile(t *testing.T, action, kind, pattern string, feedID uuid.NullUUID) Rule {
+	t.Helper()
+	rule, err := Compile(action, kind, pattern, feedID)
+	if err != nil {
+		t.Fatalf("Compile(%s, %s, %q) failed: %v", action, kind, pattern, err)
+	}
+	return rule
+}
+
+func TestMatch(t *testing.T) {
+	inGators := uuid.NullUUID{UUID: gators, Valid: true}
+	inSwamps := uuid.NullUUID{UUID: swamps, Valid: true}
+
+	tests := []struct {
+		name        string
+		kind        string
+		pattern     string
+		feedID      uuid.NullUUID
+		title       string
+		description string
+		want        bool
+	}{
+		{"keyword in title", Keyword, "sponsored", uuid.NullUUID{}, "A sponsored post", "", true},
+		{"keyword in description", Keyword, "sponsored", uuid.NullUUID{}, "A post", "<p>This is sponsored</p>", true},
+		{"keyword in neither", Keyword, "sponsored", uuid.NullUUID{}, "A post", "About mud", false},
+		{"keyword ignores case", Keyword, "SponSored", uuid.NullUUID{}, "SPONSORED: mud", "", true},
+		{"keyword is literal", Keyword, "mud.*", uuid.NullUUID{}, "mud and more", "", false},
+		{"keyword within a word", Keyword, "cat", uuid.NullUUID{}, "Concatenate", "", true},
+
+		{"regex in title", Regex, `^\[ad\]`, uuid.NullUUID{}, "[ad] Buy mud", "", true},
+		{"regex in description", Regex, `\bgiveaway\b`, uuid.NullUUID{}, "Mud", "A giveaway today", true},
+		{"regex anchors each field", Regex, `^Mud$`, uuid.NullUUID{}, "Mud", "Fresh", true},
+		{"regex doesn't span fields", Regex, `Mud Fresh`, uuid.NullUUID{}, "Mud", "Fresh", false},
+		{"regex is case sensitive", Regex, `sponsored`, uuid.NullUUID{}, "SPONSORED", "", false},
+		{"regex opts out of case", Regex, `(?i)sponsored`, uuid.NullUUID{}, "SPONSORED", "", true},
+		{"regex in neither", Regex, `\d{4}`, uuid.NullUUID{}, "Mud", "Fresh", false},
+
+		{"keyword in its feed", Keyword, "mud", inGators, "Mud", "", true},
+		{"keyword in another feed", Keyword, "mud", inSwamps, "Mud", "", false},
+		{"regex in another feed", Regex, "Mud", inSwamps, "Mud", "", false},
+		{"feed rule", Feed, "", inGators, "", "", true},
+		{"feed rule for another feed", Feed, "", inSwamps, "Mud", "Fresh", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := compile(t, Mute, tt.kind, tt.pattern, tt.feedID)
+			if got := rule.Match(gators, tt.title, tt.description); got != tt.want {
+				t.Errorf("Match(%q, %q) = %t, want %t", tt.title, tt.description, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	muteAds := compile(t, Mute, Keyword, "sponsored", uuid.NullUUID{})
+	highlightMud := compile(t, Highlight, Regex, `(?i)\bmud\b`, uuid.NullUUID{})
+	highlightGators := compile(t, Highlight, Feed, "", uuid.NullUUID{UUID: gators, Valid: true})
+	muteSwampMud := compile(t, Mute, Keyword, "mud", uuid.NullUUID{UUID: swamps, Valid: true})
+
+	tests := []struct {
+		name            string
+		rules           []Rule
+		feedID          uuid.UUID
+		title           string
+		wantMuted       bool
+		wantHighlighted bool
+	}{
+		{"no rules", nil, gators, "Mud", false, false},
+		{"no match", []Rule{muteAds, highlightMud}, gators, "Crocs", false, false},
+		{"highlight only", []Rule{muteAds, highlightMud}, gators, "Mud", false, true},
+		{"mute only", []Rule{muteAds, highlightMud}, gators, "Sponsored", true, false},
+		{"mute after highlight", []Rule{highlightMud, muteAds}, gators, "Sponsored mud", true, false},
+		{"mute before highlight", []Rule{muteAds, highlightMud}, gators, "Sponsored mud", true, false},
+		{"two highlights", []Rule{highlightMud, highlightGators}, gators, "Mud", false, true},
+		{"feed rule highlights", []Rule{highlightGators, muteSwampMud}, gators, "Mud", false, true},
+		{"scoped mute beats a highlight", []Rule{highlightMud, muteSwampMud}, swamps, "Mud", true, false},
+		{"scoped mute elsewhere", []Rule{muteSwampMud}, gators, "Mud", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			muted, highlighted := Evaluate(tt.rules, tt.feedID, tt.title, "")
+			if muted != tt.wantMuted || highlighted != tt.wantHighlighted {
+				t.Errorf("Evaluate(%q) = muted %t, highlighted %t, want %t, %t",
+					tt.title, muted, highlighted, tt.wantMuted, tt.wantHighlighted)
+			}
+		})
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	inGators := uuid.NullUUID{UUID: gators, Valid: true}
+
+	tests := []struct {
+		name    string
+		action  string
+		kind    string
+		pattern string
+		feedID  uuid.NullUUID
+	}{
+		{"unknown action", "hide", Keyword, "mud", uuid.NullUUID{}},
+		{"unknown kind", Mute, "glob", "mud*", uuid.NullUUID{}},
+		{"empty keyword", Mute, Keyword, "", inGators},
+		{"bad regex", Highlight, Regex, "mud(", uuid.NullUUID{}},
+		{"feed rule without a feed", Mute, Feed, "", uuid.NullUUID{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(tt.action, tt.kind, tt.pattern, tt.feedID); err == nil {
+				t.Errorf("Compile(%s, %s, %q) succeeded, want an error", tt.action, tt.kind, tt.pattern)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package sanitize strips feed HTML down to markup that is safe to store and
+// hand on to readers, dropping scripts, styles, event handlers, embeds and
+// javascript: links.
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowed maps every element that survives to the attributes it may keep.
+// Anything not listed is unwrapped, its children kept.
+var allowed = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Ol:         {"start"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// dropped elements go with everything inside them.
+var dropped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Noscript: true,
+	atom.Template: true,
+}
+
+var urlAttrs = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+var safeSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// HTML returns fragment with only allowed elements and attributes left.
+// Text that isn't HTML comes back escaped, so the result is always safe to
+// put in a page.
+func HTML(fragment string) string {
+	if strings.TrimSpace(fragment) == "" {
+		return ""
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		render(&b, n)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func render(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		//comments, doctypes and the like
+		return
+	}
+
+	if dropped[n.DataAtom] {
+		return
+	}
+
+	attrs, ok := allowed[n.DataAtom]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(b, c)
+		}
+		return
+	}
+
+	b.WriteString("<" + n.Data)
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !keepAttr(attrs, attr) {
+			continue
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	if n.DataAtom == atom.A {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">")
+
+	if isVoid(n.DataAtom) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(b, c)
+	}
+	b.WriteString("</" + n.Data + ">")
+}
+
+func keepAttr(attrs []string, attr html.Attribute) bool {
+	key := strings.ToLower(attr.Key)
+	found := false
+	for _, name := range attrs {
+		if name == key {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if !urlAttrs[key] {
+		return true
+	}
+	return SafeURL(attr.Val)
+}
+
+// SafeURL reports whether u is relative or uses a scheme a reader can follow
+// without running anything.
+func SafeURL(u string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "" || safeSchemes[strings.ToLower(parsed.Scheme)]
+}
+
+func isVoid(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img
+}
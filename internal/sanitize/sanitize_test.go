@@ -0,0 +1,88 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "  ", ""},
+		{"plain text", "gators & crocs < 3", "gators &amp; crocs &lt; 3"},
+		{"allowed markup", "<p>Hi <b>there</b></p>", "<p>Hi <b>there</b></p>"},
+		{"unknown element unwrapped", "<p><font color=red>Hi</font></p>", "<p>Hi</p>"},
+		{"comment", "a<!-- hidden -->b", "ab"},
+
+		{"script", `<p>Hi</p><script>alert(1)</script>`, "<p>Hi</p>"},
+		{"script in body", `<p>Hi<script>alert(1)</script> there</p>`, "<p>Hi there</p>"},
+		{"style element", `<style>p { color: red }</style><p>Hi</p>`, "<p>Hi</p>"},
+		{"iframe", `<iframe src="https://evil.example/"></iframe>ok`, "ok"},
+		{"svg", `<svg onload="alert(1)"><circle/></svg>ok`, "ok"},
+		{"uppercase script", `<SCRIPT>alert(1)</SCRIPT>ok`, "ok"},
+
+		{"event handler", `<p onclick="alert(1)">Hi</p>`, "<p>Hi</p>"},
+		{"img onerror", `<img src="a.png" onerror="alert(1)">`, `<img src="a.png">`},
+		{"mixed case handler", `<b OnMouseOver="alert(1)">Hi</b>`, "<b>Hi</b>"},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">Hi</p>`, "<p>Hi</p>"},
+		{"srcset", `<img src="a.png" srcset="javascript:alert(1) 2x, b.png 1x">`, `<img src="a.png">`},
+		{"unlisted attribute", `<a href="/x" target="_blank" class="big">x</a>`, `<a href="/x" rel="nofollow noopener noreferrer">x</a>`},
+		{"attribute escaped", `<img alt='"><script>' src="a.png">`, `<img alt="&#34;&gt;&lt;script&gt;" src="a.png">`},
+
+		{"http link", `<a href="https://gators.example/">x</a>`, `<a href="https://gators.example/" rel="nofollow noopener noreferrer">x</a>`},
+		{"mailto link", `<a href="mailto:ally@gators.example">x</a>`, `<a href="mailto:ally@gators.example" rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"mixed case javascript", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"padded javascript", `<a href="  javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity encoded javascript", `<a href="&#106;avascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"hex entity javascript", `<a href="&#x6A;&#x61;vascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"named entity colon", `<a href="javascript&colon;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"tab in scheme", "<a href=\"java\tscript:alert(1)\">x</a>", `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity encoded tab in scheme", `<a href="java&#9;script:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"data src", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, "<img>"},
+		{"mixed case data", `<img src="DaTa:text/html,<script>alert(1)</script>">`, "<img>"},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"mixed case vbscript", `<a href="VBScript:msgbox(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript cite", `<blockquote cite="javascript:alert(1)">q</blockquote>`, "<blockquote>q</blockquote>"},
+
+		{"unclosed tags", "<p>one<p>two <b>bold", "<p>one</p><p>two <b>bold</b></p>"},
+		{"unclosed script", "ok<script>alert(1)", "ok"},
+		{"nested unknown", "<div><section><article><em>deep</em></article></section></div>", "<div><em>deep</em></div>"},
+		{"script nested in allowed", "<ul><li><script>alert(1)</script>item</li></ul>", "<ul><li>item</li></ul>"},
+		{"nested dropped", "<object><embed src=x><p>inside</p></object>after", "after"},
+		{"stray closing tag", "a</div>b", "ab"},
+		{"tag inside a tag", `<img src="a.png" <script>alert(1)</script>`, `<img src="a.png">alert(1)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in); got != tt.want {
+				t.Errorf("HTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"https://gators.example/", true},
+		{"HTTP://gators.example/", true},
+		{"/relative/path", true},
+		{"#anchor", true},
+		{"mailto:ally@gators.example", true},
+		{"javascript:alert(1)", false},
+		{"JAVASCRIPT:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"data:text/html,hi", false},
+		{"vbscript:msgbox(1)", false},
+		{"java\nscript:alert(1)", false},
+		{"ftp://gators.example/", false},
+	}
+	for _, tt := range tests {
+		if got := SafeURL(tt.in); got != tt.want {
+			t.Errorf("SafeURL(%q) = %t, want %t", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Package termtext renders post HTML as plain text for the terminal:
+// paragraphs and lists keep their shape, lines are wrapped to a width and
+// links become numbered footnotes.
+package termtext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kbm-ky/gator/internal/sanitize"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultWidth is used when the terminal width is unknown.
+const DefaultWidth = 80
+
+var (
+	whitespace = regexp.MustCompile(`\s+`)
+	blankLines = regexp.MustCompile(`\n\s*\n`)
+)
+
+// list is an open <ul> or <ol>; n is the next number of an ordered list.
+type list struct {
+	ordered bool
+	n       int
+}
+
+type renderer struct {
+	width int
+	out   strings.Builder
+
+	//inline text waiting for the end of its block
+	inline strings.Builder
+	//indent for every line of the current block, and the one replacing it
+	//on the block's first line, such as a list bullet
+	prefix      string
+	firstPrefix string
+	//a blank line is due before the next line, inside gapPrefix
+	gap       bool
+	gapPrefix string
+
+	lists []list
+	links []string
+}
+
+// Render returns fragment as text wrapped to width columns, followed by its
+// links. Text without any markup is treated as paragraphs separated by blank
+// lines.
+func Render(fragment string, width int) string {
+	if width < 20 {
+		width = DefaultWidth
+	}
+	if strings.TrimSpace(fragment) == "" {
+		return ""
+	}
+
+	if !strings.Contains(fragment, "<") {
+		var paragraphs []string
+		for _, p := range blankLines.Split(strings.TrimSpace(fragment), -1) {
+			paragraphs = append(paragraphs, "<p>"+html.EscapeString(p)+"</p>")
+		}
+		fragment = strings.Join(paragraphs, "")
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return Clean(fragment)
+	}
+
+	r := &renderer{width: width}
+	for _, n := range nodes {
+		r.node(n)
+	}
+	r.flush()
+
+	if len(r.links) > 0 {
+		r.out.WriteString("\n")
+		for i, link := range r.links {
+			fmt.Fprintf(&r.out, "[%d] %s\n", i+1, link)
+		}
+	}
+
+	return Clean(strings.TrimRight(r.out.String(), "\n"))
+}
+
+// Clean drops C0 and C1 control characters other than newlines and tabs,
+// so text from a feed can't move the cursor, retitle the window or write to
+// the clipboard with escape sequences. Invalid UTF-8 becomes U+FFFD.
+func Clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r < 0x20, r >= 0x7f && r <= 0x9f:
+			return -1
+		default:
+			return r
+		}
+	}, s)
+}
+
+func (r *renderer) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.inline.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title, atom.Noscript, atom.Template:
+		return
+
+	case atom.Br:
+		r.flush()
+
+	case atom.Hr:
+		r.block()
+		r.line(r.prefix, strings.Repeat("─", max(r.width-utf8.RuneCountInString(r.prefix), 1)))
+		r.breakAfter()
+
+	case atom.Img:
+		alt := strings.TrimSpace(attr(n, "alt"))
+		if alt == "" {
+			r.inline.WriteString(" [image]")
+		} else {
+			r.inline.WriteString(" [image: " + alt + "]")
+		}
+		if src := attr(n, "src"); src != "" && sanitize.SafeURL(src) {
+			r.footnote(src)
+		}
+		r.inline.WriteString(" ")
+
+	case atom.A:
+		r.children(n)
+		if href := strings.TrimSpace(attr(n, "href")); href != "" && !strings.HasPrefix(href, "#") && sanitize.SafeURL(href) {
+			r.footnote(href)
+		}
+
+	case atom.P, atom.Div, atom.Figure, atom.Figcaption, atom.Dl, atom.Table, atom.Section, atom.Article:
+		r.block()
+		r.children(n)
+		r.block()
+
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.block()
+		level := int(n.Data[1] - '0')
+		r.inline.WriteString(strings.Repeat("#", level) + " ")
+		r.children(n)
+		r.block()
+
+	case atom.Blockquote:
+		r.block()
+		saved := r.prefix
+		r.prefix += "> "
+		r.firstPrefix = r.prefix
+		r.children(n)
+		r.flush()
+		r.prefix = saved
+		r.firstPrefix = saved
+		//the blank line after a quote is outside it
+		r.gap = true
+		r.gapPrefix = saved
+
+	case atom.Pre:
+		r.block()
+		r.pre(n)
+		r.breakAfter()
+
+	case atom.Ul, atom.Ol:
+		if len(r.lists) == 0 {
+			r.block()
+		} else {
+			r.flush()
+		}
+		l := list{ordered: n.DataAtom == atom.Ol, n: 1}
+		if _, err := fmt.Sscan(attr(n, "start"), &l.n); err != nil {
+			l.n = 1
+		}
+		//nested lists are already indented by their item
+		r.lists = append(r.lists, l)
+		r.children(n)
+		r.flush()
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.breakAfter()
+		}
+
+	case atom.Li:
+		r.flush()
+		bullet := "• "
+		if len(r.lists) > 0 && r.lists[len(r.lists)-1].ordered {
+			top := &r.lists[len(r.lists)-1]
+			bullet = fmt.Sprintf("%d. ", top.n)
+			top.n++
+		}
+		saved := r.prefix
+		r.firstPrefix = saved + bullet
+		r.prefix = saved + strings.Repeat(" ", utf8.RuneCountInString(bullet))
+		r.children(n)
+		r.flush()
+		r.prefix = saved
+		r.firstPrefix = saved
+
+	case atom.Dt, atom.Tr:
+		r.flush()
+		r.children(n)
+		r.flush()
+
+	case atom.Dd:
+		r.flush()
+		saved := r.prefix
+		r.prefix += "    "
+		r.firstPrefix = r.prefix
+		r.children(n)
+		r.flush()
+		r.prefix = saved
+		r.firstPrefix = saved
+
+	case atom.Td, atom.Th:
+		r.children(n)
+		r.inline.WriteString(" | ")
+
+	default:
+		r.children(n)
+	}
+}
+
+func (r *renderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.node(c)
+	}
+}
+
+func (r *renderer) footnote(link string) {
+	r.links = append(r.links, link)
+	fmt.Fprintf(&r.inline, "[%d]", len(r.links))
+}
+
+// block ends whatever came before and leaves a blank line before what
+// follows.
+func (r *renderer) block() {
+	r.flush()
+	r.breakAfter()
+}
+
+// breakAfter asks for a blank line before the next one. The first request
+// wins, so a quote's blank line doesn't get the quote's prefix.
+func (r *renderer) breakAfter() {
+	if r.gap {
+		return
+	}
+	r.gap = true
+	r.gapPrefix = r.prefix
+}
+
+// flush wraps the pending inline text into lines.
+func (r *renderer) flush() {
+	text := strings.TrimSpace(whitespace.ReplaceAllString(r.inline.String(), " "))
+	text = strings.TrimSuffix(text, " |")
+	r.inline.Reset()
+	if text == "" {
+		return
+	}
+
+	first := r.firstPrefix
+	if first == "" {
+		first = r.prefix
+	}
+
+	prefix := first
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		room := r.width - utf8.RuneCountInString(prefix)
+		if line.Len() > 0 && utf8.RuneCountInString(line.String())+1+utf8.RuneCountInString(word) > room {
+			r.line(prefix, line.String())
+			line.Reset()
+			prefix = r.prefix
+		}
+		if line.Len() > 0 {
+			line.WriteString(" ")
+		}
+		line.WriteString(word)
+	}
+	r.line(prefix, line.String())
+
+	//only the first line of a list item carries the bullet
+	r.firstPrefix = r.prefix
+}
+
+// pre copies preformatted text as is, only indenting it.
+func (r *renderer) pre(n *html.Node) {
+	var text strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	for _, l := range strings.Split(strings.Trim(text.String(), "\n"), "\n") {
+		r.line(r.prefix+"    ", strings.TrimRight(l, " \t\r"))
+	}
+}
+
+func (r *renderer) line(prefix, text string) {
+	if r.gap && r.out.Len() > 0 {
+		r.out.WriteString(strings.TrimRight(r.gapPrefix, " ") + "\n")
+	}
+	r.gap = false
+	r.out.WriteString(strings.TrimRight(prefix+text, " ") + "\n")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package termtext
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		width int
+		want  string
+	}{
+		{"empty", " ", 40, ""},
+		{"plain paragraphs", "one\n\ntwo", 40, "one\n\ntwo"},
+		{"wrapped", "<p>the quick brown fox jumps over the lazy dog</p>", 20, "the quick brown fox\njumps over the lazy\ndog"},
+		{"link footnote", `<p>see <a href="https://gators.example/">here</a></p>`, 40, "see here[1]\n\n[1] https://gators.example/"},
+		{"unsafe link", `<p><a href="javascript:alert(1)">here</a></p>`, 40, "here"},
+		{"list", "<ul><li>one</li><li>two</li></ul>", 40, "• one\n• two"},
+		{"ordered list", `<ol start="3"><li>three</li><li>four</li></ol>`, 40, "3. three\n4. four"},
+		{"quote", "<blockquote><p>hi</p></blockquote><p>after</p>", 40, "> hi\n\nafter"},
+		{"script dropped", "<p>hi<script>alert(1)</script></p>", 40, "hi"},
+		{"escape sequences", "<p>a\x1b]52;c;Z2F0b3I=\x07b \x1b[2Jc</p>", 40, "a]52;c;Z2F0b3I=b [2Jc"},
+		{"c1 control", "<p>a\u009b31mb</p>", 40, "a31mb"},
+		{"escape in plain text", "one\x1b[31m red", 40, "one[31m red"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in, tt.width); got != tt.want {
+				t.Errorf("Render(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"keeps\nnewlines\tand tabs", "keeps\nnewlines\tand tabs"},
+		{"\x1b[31mred\x1b[0m", "[31mred[0m"},
+		{"osc 52\x1b]52;c;aGk=\x07", "osc 52]52;c;aGk="},
+		{"carriage\rreturn", "carriagereturn"},
+		{"nul\x00del\x7f", "nuldel"},
+		{"c1\u0085\u009b\u009d", "c1"},
+		{"bad \xff utf-8", "bad � utf-8"},
+		{"émoji 🐊", "émoji 🐊"},
+	}
+	for _, tt := range tests {
+		if got := Clean(tt.in); got != tt.want {
+			t.Errorf("Clean(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -3,39 +3,61 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
-	"html"
 	"io"
-	"log"
-	"net/http"
+	"log/slog"
+	"maps"
+	"math"
 	"os"
-	"strconv"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/kbm-ky/gator/internal/config"
 	"github.com/kbm-ky/gator/internal/database"
-	_ "github.com/lib/pq"
+	"github.com/kbm-ky/gator/internal/dateparse"
+	"github.com/kbm-ky/gator/internal/sanitize"
+	"github.com/lib/pq"
 )
 
 func main() {
+	//Global flags come before the sub-command
+	logLevel := flag.String("log-level", "", "log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "", "log format: text or json")
+	flag.Parse()
+
 	//Read config
 	configFile, err := config.Read()
 	if err != nil {
-		log.Fatalf("unable to read config: %v", err)
+		slog.Error("unable to read config", "error", err)
+		os.Exit(1)
+	}
+
+	if *logLevel == "" {
+		*logLevel = configFile.LogLevel
+	}
+	if *logFormat == "" {
+		*logFormat = configFile.LogFormat
+	}
+	logger, err := newLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		slog.Error("unable to set up logging", "error", err)
+		os.Exit(1)
 	}
 
 	//Prepare database
 	db, err := sql.Open("postgres", configFile.DbUrl)
 	if err != nil {
-		log.Fatalf("unable to open databse: %s", configFile.DbUrl)
+		logger.Error("unable to open database", "error", err)
+		os.Exit(1)
 	}
 
 	dbQueries := database.New(db)
 
 	// Prepare sub commands
-	s := state{db: dbQueries, cfg: &configFile}
+	s := state{db: dbQueries, conn: db, cfg: &configFile, logger: logger}
 	cmds := commands{
 		handlers: map[string]func(*state, command) error{},
 	}
@@ -46,31 +68,55 @@ func main() {
 	cmds.register("agg", handlerAgg)
 	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
 	cmds.register("feeds", handlerFeeds)
+	cmds.register("discover", handlerDiscover)
+	cmds.register("pollinterval", middlewareLoggedIn(handlerPollInterval))
+	cmds.register("fullarticle", middlewareLoggedIn(handlerFullArticle))
+	cmds.register("retention", middlewareLoggedIn(handlerRetention))
+	cmds.register("prune", handlerPrune)
+	cmds.register("download", middlewareLoggedIn(handlerDownload))
 	cmds.register("follow", middlewareLoggedIn(handlerFollow))
 	cmds.register("following", middlewareLoggedIn(handlerFollowing))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
+	cmds.register("tag", middlewareLoggedIn(handlerTag))
+	cmds.register("untag", middlewareLoggedIn(handlerUntag))
+	cmds.register("rename", middlewareLoggedIn(handlerRename))
+	cmds.register("priority", middlewareLoggedIn(handlerPriority))
 	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	cmds.register("search", middlewareLoggedIn(handlerSearch))
+	cmds.register("read", middlewareLoggedIn(handlerRead))
+	cmds.register("open", middlewareLoggedIn(handlerOpen))
+	cmds.register("show", middlewareLoggedIn(handlerShow))
+	cmds.register("tui", middlewareLoggedIn(handlerTUI))
+	cmds.register("publish", middlewareLoggedIn(handlerPublish))
+	cmds.register("feedtoken", middlewareLoggedIn(handlerFeedToken))
+	cmds.register("apipassword", middlewareLoggedIn(handlerApiPassword))
+	cmds.register("webhooks", middlewareLoggedIn(handlerWebhooks))
+	cmds.register("rules", middlewareLoggedIn(handlerRules))
+	cmds.register("digest", handlerDigest)
+	cmds.register("serve", handlerServe)
 
 	//finally check command line and dispatch
-	if len(os.Args) < 2 {
-		fmt.Printf("expecting sub-command, got nothing\n")
+	if flag.NArg() < 1 {
+		logger.Error("expecting sub-command, got nothing")
 		os.Exit(1)
 	}
 
 	command := command{
-		name: os.Args[1],
-		args: os.Args[2:],
+		name: flag.Arg(0),
+		args: flag.Args()[1:],
 	}
 
 	if err := cmds.run(&s, command); err != nil {
-		fmt.Printf("error: %v\n", err)
+		logger.Error("command failed", "command", command.name, "error", err)
 		os.Exit(1)
 	}
 }
 
 type state struct {
-	db  *database.Queries
-	cfg *config.Config
+	db     *database.Queries
+	conn   *sql.DB
+	cfg    *config.Config
+	logger *slog.Logger
 }
 
 type command struct {
@@ -104,8 +150,7 @@ func handlerLogin(s *state, cmd command) error {
 	userName := cmd.args[0]
 	_, err := s.db.GetUser(context.Background(), userName)
 	if err != nil {
-		log.Printf("user does not exist! %s\n", userName)
-		os.Exit(1)
+		return fmt.Errorf("user does not exist! %s [%w]", userName, err)
 	}
 
 	if err := s.cfg.SetUser(userName); err != nil {
@@ -133,11 +178,12 @@ func handlerRegister(s *state, cmd command) error {
 	}
 	user, err := s.db.CreateUser(context.Background(), userParams)
 	if err != nil {
-		log.Printf("name already exists! %s", name)
-		os.Exit(1)
+		return fmt.Errorf("name already exists! %s [%w]", name, err)
 	}
 
-	s.cfg.SetUser(name)
+	if err := s.cfg.SetUser(name); err != nil {
+		return fmt.Errorf("unable to set user: %w", err)
+	}
 	fmt.Printf("user '%s' created\n", name)
 	fmt.Printf("user = %v\n", user)
 
@@ -147,8 +193,7 @@ func handlerRegister(s *state, cmd command) error {
 func handlerReset(s *state, cmd command) error {
 	err := s.db.DeleteAllUsers(context.Background())
 	if err != nil {
-		log.Printf("unable to delete all users! %v", err)
-		os.Exit(1)
+		return fmt.Errorf("unable to delete all users! %w", err)
 	}
 	return nil
 }
@@ -156,8 +201,7 @@ func handlerReset(s *state, cmd command) error {
 func handlerUsers(s *state, cmd command) error {
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
-		log.Printf("unable to get all usrs! %v", err)
-		os.Exit(1)
+		return fmt.Errorf("unable to get all users! %w", err)
 	}
 
 	for _, user := range users {
@@ -172,33 +216,76 @@ func handlerUsers(s *state, cmd command) error {
 }
 
 func handlerAgg(s *state, cmd command) error {
+	flags := flag.NewFlagSet("agg", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	tag := flags.String("tag", "", "only fetch feeds the current user tagged with this")
+	prune := flags.Bool("prune", false, "prune old posts every hour")
+	if err := flags.Parse(cmd.args); err != nil {
+		return fmt.Errorf("agg: %w", err)
+	}
+	args := flags.Args()
+
 	//check args
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("agg expects 1 argument, time_between_reqs")
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("agg expects 1 or 2 arguments, [--tag name] [--prune] time_between_reqs [metrics_addr]")
 	}
-	time_between_reqs := cmd.args[0]
+	time_between_reqs := args[0]
 
 	duration, err := time.ParseDuration(time_between_reqs)
 	if err != nil {
 		return fmt.Errorf("unable to parse time between requests: %w", err)
 	}
 
+	//Catch a bad min/max poll interval before the first fetch rather than after it
+	if _, err := pollIntervalBounds(s.cfg); err != nil {
+		return err
+	}
+	if _, err := globalRetention(s.cfg); err != nil {
+		return err
+	}
+
+	//A tag belongs to a user, so --tag means the logged in user's tag
+	var filter database.GetNextFeedToFetchParams
+	if name := normalizeTag(*tag); name != "" {
+		user, err := s.db.GetUser(context.Background(), s.cfg.CurrentUserName)
+		if err != nil {
+			return fmt.Errorf("unable to get current user: %w", err)
+		}
+		filter.TagUserID = uuid.NullUUID{UUID: user.ID, Valid: true}
+		filter.Tag = nullString(name)
+	}
+
+	if len(args) == 2 {
+		metricsAddr := args[1]
+		if err := serveMetrics(metricsAddr); err != nil {
+			return fmt.Errorf("unable to serve metrics: %w", err)
+		}
+		fmt.Printf("Serving metrics on %s/metrics\n", metricsAddr)
+	}
+
+	go runWebhookWorker(s, webhookClient)
+	go runArticleWorker(s)
+
+	var lastPruned time.Time
 	ticker := time.NewTicker(duration)
 	for ; ; <-ticker.C {
 		fmt.Printf("Collect feeds every %s\n", duration.String())
-		if err := scrapeFeeds(s); err != nil {
+		if err := scrapeFeeds(s, filter); err != nil {
 			return fmt.Errorf("unable to scrap feed: %w", err)
 		}
+		if err := updateStalestFeedAge(s); err != nil {
+			s.logger.Warn("unable to update stalest feed age", "error", err)
+		}
+		if *prune && time.Since(lastPruned) >= pruneEvery {
+			lastPruned = time.Now()
+			if _, deleted, err := prunePosts(context.Background(), s, false); err != nil {
+				s.logger.Error("unable to prune posts", "error", err)
+			} else {
+				s.logger.Info("pruned posts", "deleted", deleted)
+			}
+		}
 		fmt.Println()
 	}
-	// rssFeed, err := fetchFeed(context.Background(), "https://www.wagslane.dev/index.xml")
-	// if err != nil {
-	// 	return fmt.Errorf("unable to fetch feed: %w", err)
-	// }
-
-	// fmt.Printf("%v\n", *rssFeed)
-
-	return nil
 }
 
 func handlerAddFeed(s *state, cmd command, user database.User) error {
@@ -208,6 +295,21 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 
 	name, url := cmd.args[0], cmd.args[1]
 
+	//Homepages are swapped for the feed they advertise
+	candidates, err := discoverFeeds(context.Background(), s, url)
+	if err != nil {
+		s.logger.Warn("unable to discover feed, adding url as given", "url", url, "error", err)
+	} else {
+		candidate, err := chooseCandidate(url, candidates)
+		if err != nil {
+			return err
+		}
+		if candidate.Url != url {
+			fmt.Printf("using feed %s\n", candidate.Url)
+		}
+		url = candidate.Url
+	}
+
 	now := time.Now()
 	params := database.CreateFeedParams{
 		ID:        uuid.New(),
@@ -258,6 +360,13 @@ func handlerFeeds(s *state, cmd command) error {
 		fmt.Printf("Name: %s\n", feed.Name)
 		fmt.Printf("URL: %s\n", feed.Url)
 		fmt.Printf("User: %s\n", user.Name)
+		fmt.Printf("Poll interval: %s\n", describePollInterval(feed))
+		if feed.FetchFullArticle {
+			fmt.Printf("Full articles: on\n")
+		}
+		if feed.RetentionMaxAgeSeconds.Valid || feed.RetentionMaxPosts.Valid {
+			fmt.Printf("Retention: %s\n", describeRetention(feed))
+		}
 		fmt.Println()
 	}
 
@@ -270,10 +379,29 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 	}
 	url := cmd.args[0]
 	feed, err := s.db.GetFeedByUrl(context.Background(), url)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		feed, err = findDiscoveredFeed(context.Background(), s, url)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
 		return fmt.Errorf("unable to get feed by url: %w", err)
 	}
 
+	feed_follow, err := followFeed(context.Background(), s, user, feed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created feed_follow:\n")
+	fmt.Printf("Feed Name: %s\n", feed_follow.FeedName)
+	fmt.Printf("User Name: %s\n", feed_follow.UserName)
+
+	return nil
+}
+
+// followFeed has user follow feed.
+func followFeed(ctx context.Context, s *state, user database.User, feed database.Feed) (database.CreateFeedFollowRow, error) {
 	now := time.Now()
 	params := database.CreateFeedFollowParams{
 		ID:        uuid.New(),
@@ -283,28 +411,68 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 		FeedID:    feed.ID,
 	}
 
-	feed_follow, err := s.db.CreateFeedFollow(context.Background(), params)
+	feed_follow, err := s.db.CreateFeedFollow(ctx, params)
 	if err != nil {
-		return fmt.Errorf("unable to create feed_follow: %w", err)
+		return database.CreateFeedFollowRow{}, fmt.Errorf("unable to create feed_follow: %w", err)
 	}
-
-	fmt.Printf("created feed_follow:\n")
-	fmt.Printf("Feed Name: %s\n", feed_follow.FeedName)
-	fmt.Printf("User Name: %s\n", feed_follow.UserName)
-
-	return nil
+	return feed_follow, nil
 }
 
 func handlerFollowing(s *state, cmd command, user database.User) error {
+	if len(cmd.args) > 1 {
+		return fmt.Errorf("following expects at most 1 argument: [tag]")
+	}
+
 	currentUser := user.Name
 	feed_follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return fmt.Errorf("unable to get feed follows for user: %w", err)
 	}
+	tags, err := feedTags(context.Background(), s, user)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("Feeds %s is following:\n", currentUser)
+	if len(cmd.args) == 1 {
+		tag := normalizeTag(cmd.args[0])
+		fmt.Printf("Feeds %s is following tagged %s:\n", currentUser, tag)
+		for _, feed_follow := range feed_follows {
+			if slices.Contains(tags[feed_follow.FeedID], tag) {
+				fmt.Println(followLabel(feed_follow))
+			}
+		}
+		return nil
+	}
+
+	//Grouped by tag, a feed showing up under each of its tags
+	byTag := map[string][]string{}
+	var untagged []string
 	for _, feed_follow := range feed_follows {
-		fmt.Println(feed_follow.FeedName)
+		if len(tags[feed_follow.FeedID]) == 0 {
+			untagged = append(untagged, followLabel(feed_follow))
+			continue
+		}
+		for _, tag := range tags[feed_follow.FeedID] {
+			byTag[tag] = append(byTag[tag], followLabel(feed_follow))
+		}
+	}
+
+	fmt.Printf("Feeds %s is following:\n", currentUser)
+	for _, tag := range slices.Sorted(maps.Keys(byTag)) {
+		fmt.Printf("[%s]\n", tag)
+		for _, name := range byTag[tag] {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	if len(byTag) == 0 {
+		for _, name := range untagged {
+			fmt.Println(name)
+		}
+	} else if len(untagged) > 0 {
+		fmt.Println("[untagged]")
+		for _, name := range untagged {
+			fmt.Printf("  %s\n", name)
+		}
 	}
 
 	return nil
@@ -339,35 +507,62 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 }
 
 func handlerBrowse(s *state, cmd command, user database.User) error {
-	limitStr := "2"
-	if len(cmd.args) > 0 {
-		limitStr = cmd.args[0]
+	filters, args, err := parsePostFilters(cmd.name, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("browse expects at most 1 argument: [--author name] [--category name] [--tag name] [limit]")
 	}
 
-	limit, err := strconv.ParseInt(limitStr, 10, 32)
+	limit, err := parseLimit(args)
 	if err != nil {
-		return fmt.Errorf("unable to parse limit: %s [%w]", limitStr, err)
+		return err
 	}
 	if limit < 2 {
-		log.Printf("changing limit to 2")
+		s.logger.Info("changing limit to 2", "requested", limit)
 		limit = 2
 	}
 
-	args := database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
-	}
-
-	posts, err := s.db.GetPostsForUser(context.Background(), args)
+	userRules, err := loadRules(s, user)
 	if err != nil {
-		return fmt.Errorf("unable to get posts for user: %w", err)
-	}
+		return fmt.Errorf("unable to load rules: %w", err)
+	}
+
+	//Muted posts don't count towards the limit, so keep paging until it is met
+	shown := int64(0)
+	for offset := int64(0); shown < limit; offset += limit {
+		args := database.GetPostsForUserParams{
+			UserID:   user.ID,
+			Limit:    int32(limit),
+			Offset:   int32(offset),
+			Author:   filters.Author,
+			Category: filters.Category,
+			Tag:      filters.Tag,
+		}
 
-	for _, post := range posts {
-		fmt.Printf("Title: %s\n", post.Title.String)
-		fmt.Printf("Url: %s\n", post.Url)
-		fmt.Printf("Description: %s\n", post.Description.String)
-		fmt.Println()
+		posts, err := s.db.GetPostsForUser(context.Background(), args)
+		if err != nil {
+			return fmt.Errorf("unable to get posts for user: %w", err)
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		for _, post := range posts {
+			printed, err := printPost(s, post, userRules)
+			if err != nil {
+				return err
+			}
+			if !printed {
+				continue
+			}
+
+			shown++
+			if shown == limit {
+				break
+			}
+		}
 	}
 
 	return nil
@@ -380,143 +575,204 @@ func middlewareLoggedIn(handler func(s *state, cmd command, user database.User)
 			return fmt.Errorf("unable to get user: %w", err)
 		}
 
-		return handler(s, cmd, user)
+		//Everything logged on behalf of this user says so
+		userState := *s
+		userState.logger = s.logger.With("user", user.Name)
+
+		return handler(&userState, cmd, user)
 	}
 }
 
-func scrapeFeeds(s *state) error {
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+// scrapeFeeds fetches the feed most overdue for a fetch, limited to a user's
+// tag when filter has one.
+func scrapeFeeds(s *state, filter database.GetNextFeedToFetchParams) error {
+	filter.Now = time.Now()
+	feed, err := s.db.GetNextFeedToFetch(context.Background(), filter)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Debug("no feeds are due")
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("unable to get next feed to fetch: %w", err)
 	}
 
+	return scrapeFeed(s, feed)
+}
+
+// scrapeFeed fetches one feed, saves its new posts and schedules its next
+// fetch.
+func scrapeFeed(s *state, feed database.Feed) error {
+	logger := s.logger.With("feed_id", feed.ID, "feed_url", feed.Url)
+
 	now := time.Now()
 	markArgs := database.MarkFeedFetchedParams{
 		ID:            feed.ID,
 		LastFetchedAt: sql.NullTime{Time: now, Valid: true},
 		UpdatedAt:     now,
 	}
-	err = s.db.MarkFeedFetched(context.Background(), markArgs)
-	if err != nil {
+	if err := s.db.MarkFeedFetched(context.Background(), markArgs); err != nil {
 		return fmt.Errorf("unable to mark feed fetched: %w", err)
 	}
 
-	rssFeed, err := fetchFeed(context.Background(), feed.Url)
+	stream, err := openFeed(context.Background(), feed.Url, maxFeedBytes(s))
 	if err != nil {
-		log.Printf("unable to fetch feed: %v", err)
+		logger.Error("unable to fetch feed", "error", err)
+		next, err := scheduleRetry(context.Background(), s, feed, time.Now())
+		if err != nil {
+			return fmt.Errorf("unable to schedule feed retry: %w", err)
+		}
+		logger.Debug("scheduled retry", "next_fetch_at", next, "failures", feed.FetchFailures+1)
+		return nil
 	}
+	defer stream.Close()
 
-	// fmt.Printf("Channel: %s\n", rssFeed.Channel.Title)
-	// for _, item := range rssFeed.Channel.Item {
-	// 	fmt.Printf("Title: %s\n", item.Title)
-	// }
-
-	//Saving to posts
-	for _, item := range rssFeed.Channel.Item {
-		now := time.Now()
-
-		// t, err := time.Parse(time.RFC3339, item.PubDate)
-		t, err := parseTime(item.PubDate)
-		publishedAt := sql.NullTime{}
+	if stream.PermanentURL != "" && stream.PermanentURL != feed.Url {
+		moved, err := moveFeed(context.Background(), s, feed, stream.PermanentURL)
 		if err != nil {
-			log.Printf("unable to parse time: %s", item.PubDate)
+			logger.Error("unable to update moved feed", "new_url", stream.PermanentURL, "error", err)
 		} else {
-			publishedAt.Time = t
-			publishedAt.Valid = true
+			logger.Info("feed moved permanently", "new_url", moved.Url, "merged_into", moved.ID)
+			feed = moved
 		}
+	}
 
-		title := sql.NullString{}
-		if item.Title != "" {
-			title.String = item.Title
-			title.Valid = true
+	//Saving to posts as they are decoded, keeping whatever came before a parse error
+	seenAt := time.Now()
+	items, complete := 0, true
+	for item, err := range stream.Items() {
+		if err != nil {
+			logger.Error("unable to parse feed", "error", err)
+			complete = false
+			break
 		}
+		savePost(s, logger, feed, item)
+		items++
+	}
 
-		descr := sql.NullString{}
-		if item.Description != "" {
-			descr.String = item.Description
-			descr.Valid = true
+	//Only a feed that was read to the end counts as fresh, see updateStalestFeedAge
+	if complete {
+		successArgs := database.MarkFeedSucceededParams{
+			ID:              feed.ID,
+			LastSucceededAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt:       time.Now(),
+		}
+		if err := s.db.MarkFeedSucceeded(context.Background(), successArgs); err != nil {
+			logger.Error("unable to mark feed succeeded", "error", err)
 		}
+	}
 
-		args := database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   now,
-			UpdatedAt:   now,
-			Title:       title,
-			Url:         item.Link,
-			Description: descr,
-			PublishedAt: publishedAt,
-			FeedID:      feed.ID,
+	//Every post seen from here on is in the feed's current window, which
+	//pruning leaves alone
+	if complete && items > 0 {
+		seenArgs := database.SetFeedItemsSeenParams{
+			ID:          feed.ID,
+			ItemsSeenAt: sql.NullTime{Time: seenAt, Valid: true},
+			UpdatedAt:   time.Now(),
 		}
-		_, err = s.db.CreatePost(context.Background(), args)
-		if err != nil {
-			log.Printf("unable to create post: %v", err)
+		if err := s.db.SetFeedItemsSeen(context.Background(), seenArgs); err != nil {
+			logger.Error("unable to record feed items seen", "error", err)
 		}
-
 	}
 
-	return nil
-}
-
-func parseTime(timeString string) (time.Time, error) {
-	t, err := time.Parse(time.RFC3339, timeString)
-	if err == nil {
-		return t, nil
+	if err := recordFeedHub(context.Background(), s, feed, stream.Channel); err != nil {
+		logger.Error("unable to record websub hub", "error", err)
 	}
 
-	t, err = time.Parse(time.RFC1123Z, timeString)
-	if err == nil {
-		return t, nil
+	now = time.Now()
+	next, interval, err := scheduleFeed(context.Background(), s, feed, stream, now)
+	if err != nil {
+		return fmt.Errorf("unable to schedule feed: %w", err)
 	}
+	scheduleArgs := database.SetFeedScheduleParams{
+		ID:                  feed.ID,
+		NextFetchAt:         sql.NullTime{Time: next, Valid: true},
+		PollIntervalSeconds: sql.NullInt32{Int32: int32(min(interval/time.Second, math.MaxInt32)), Valid: true},
+		UpdatedAt:           now,
+	}
+	if err := s.db.SetFeedSchedule(context.Background(), scheduleArgs); err != nil {
+		return fmt.Errorf("unable to set feed schedule: %w", err)
+	}
+	logger.Debug("scheduled next fetch", "next_fetch_at", next, "interval", interval)
 
-	return time.Time{}, fmt.Errorf("unable to parse time: %s", timeString)
-}
-
-type RSSFeed struct {
-	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
-	} `xml:"channel"`
+	return nil
 }
 
-type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-}
+// savePost stores a feed item as a post and queues it for webhooks. Polling
+// and WebSub pushes both go through here so items are treated the same way.
+func savePost(s *state, logger *slog.Logger, feed database.Feed, item RSSItem) {
+	now := time.Now()
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("unable to make new requst: %w", err)
-	}
-	req.Header.Set("User-Agent", "gator")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("unable to do request: %w", err)
+	//Fall back to when we first saw the post so it still sorts sensibly
+	publishedAt := sql.NullTime{Time: now, Valid: true}
+	if t, err := dateparse.Parse(item.PubDate); err != nil {
+		logger.Debug("unable to parse time, using first seen", "pub_date", item.PubDate, "post_url", item.Link)
+	} else {
+		publishedAt.Time = t
+	}
+
+	title := sql.NullString{}
+	if item.Title != "" {
+		title.String = item.Title
+		title.Valid = true
+	}
+
+	//Stored bodies are served to readers as is, so only safe markup is kept
+	descr := sql.NullString{}
+	if description := sanitize.HTML(item.Description); description != "" {
+		descr.String = description
+		descr.Valid = true
+	}
+
+	args := database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Title:       title,
+		Url:         item.Link,
+		Description: descr,
+		PublishedAt: publishedAt,
+		FeedID:      feed.ID,
+		Content:     nullString(sanitize.HTML(item.content())),
+		Author:      nullString(item.author()),
+		CommentsUrl: nullString(item.commentsURL()),
+	}
+	post, err := s.db.CreatePost(context.Background(), args)
+	if isUniqueViolation(err) {
+		postsSaved.Inc("duplicate")
+		seenArgs := database.MarkPostSeenParams{
+			Url:    item.Link,
+			SeenAt: now,
+		}
+		if err := s.db.MarkPostSeen(context.Background(), seenArgs); err != nil {
+			logger.Error("unable to mark post seen", "post_url", item.Link, "error", err)
+		}
+		return
 	}
-	defer resp.Body.Close()
-
-	xmlBlob, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read response body: %w", err)
+		logger.Error("unable to create post", "post_url", item.Link, "error", err)
+		return
 	}
+	postsSaved.Inc("inserted")
 
-	var rssFeed RSSFeed
-	if err := xml.Unmarshal(xmlBlob, &rssFeed); err != nil {
-		return nil, fmt.Errorf("unable to unmarshal xml: %w", err)
+	saveEnclosures(s, logger, post, item)
+	savePostCategories(s, logger, post, item)
+	if feed.FetchFullArticle {
+		queueArgs := database.QueuePostArticleParams{
+			ID:       post.ID,
+			QueuedAt: now,
+		}
+		if err := s.db.QueuePostArticle(context.Background(), queueArgs); err != nil {
+			logger.Error("unable to queue full article", "post_url", item.Link, "error", err)
+		}
 	}
 
-	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
-	rssFeed.Channel.Description = html.UnescapeString(rssFeed.Channel.Description)
-	for i := range rssFeed.Channel.Item {
-		raw := rssFeed.Channel.Item[i].Description
-		rssFeed.Channel.Item[i].Description = html.UnescapeString(raw)
-		raw = rssFeed.Channel.Item[i].Title
-		rssFeed.Channel.Item[i].Title = html.UnescapeString(raw)
+	if err := enqueueWebhooks(s, post); err != nil {
+		logger.Error("unable to enqueue webhooks", "post_url", item.Link, "error", err)
 	}
+}
 
-	return &rssFeed, nil
+// isUniqueViolation reports whether err is postgres refusing a duplicate key.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
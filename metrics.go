@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/metrics"
+)
+
+var (
+	aggRegistry = metrics.NewRegistry()
+
+	feedFetches = aggRegistry.NewCounter("gator_feed_fetches_total",
+		"Feed fetches by HTTP status, or \"error\" when no response was received.", "status")
+	feedScrapeDuration = aggRegistry.NewHistogram("gator_feed_scrape_duration_seconds",
+		"Time to fetch and store a feed, whose items are saved as its body streams in.", metrics.DefaultBuckets)
+	feedFetchBytes = aggRegistry.NewCounter("gator_feed_fetch_bytes_total",
+		"Bytes downloaded from feeds, as sent before decompression.")
+	feedParseFailures = aggRegistry.NewCounter("gator_feed_parse_failures_total",
+		"Feeds that were downloaded but could not be parsed.")
+	postsSaved = aggRegistry.NewCounter("gator_posts_total",
+		"Feed items seen by the aggregator, by whether they were inserted or already stored.", "result")
+	stalestFeedAge = aggRegistry.NewGauge("gator_stalest_feed_age_seconds",
+		"Seconds since the feed that has gone longest without a successful fetch last had one.")
+)
+
+// serveMetrics starts the /metrics endpoint in the background. Listening
+// happens up front so a bad address is reported before agg starts looping.
+func serveMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", aggRegistry.Handler())
+
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// updateStalestFeedAge sets the gauge from the feed that has gone longest
+// without a successful fetch, counting a feed that never succeeded from
+// when it was added.
+func updateStalestFeedAge(s *state) error {
+	lastSucceeded, err := s.db.GetStalestFeedSuccess(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		//no feeds, so none are stale
+		stalestFeedAge.Set(0)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get stalest feed: %w", err)
+	}
+
+	stalestFeedAge.Set(time.Since(lastSucceeded).Seconds())
+	return nil
+}
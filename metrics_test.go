@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metricValue reads one unlabelled metric off the agg registry.
+func metricValue(t *testing.T, name string) float64 {
+	t.Helper()
+	var out bytes.Buffer
+	if err := aggRegistry.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), name+" ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	t.Fatalf("no %s metric", name)
+	return 0
+}
+
+func TestFeedFetchBytesCountsWire(t *testing.T) {
+	feed := "<rss><channel><title>Gators</title>" + strings.Repeat("<item><title>Crocs love mud</title></item>", 200) + "</channel></rss>"
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(feed))
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	before := metricValue(t, "gator_feed_fetch_bytes_total")
+	stream, err := openFeed(context.Background(), server.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range stream.Items() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.Close()
+
+	got := metricValue(t, "gator_feed_fetch_bytes_total") - before
+	if got != float64(compressed.Len()) {
+		t.Errorf("counted %v bytes, want the %d sent rather than the %d decompressed", got, compressed.Len(), len(feed))
+	}
+}
+
+func TestUpdateStalestFeedAge(t *testing.T) {
+	s := newTestState(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("<rss><channel><title>Gators</title></channel></rss>"))
+	}))
+	t.Cleanup(server.Close)
+
+	//No feeds at all
+	if err := updateStalestFeedAge(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := metricValue(t, "gator_stalest_feed_age_seconds"); got != 0 {
+		t.Errorf("age with no feeds = %v, want 0", got)
+	}
+
+	user := createTestUser(t, s, "alice")
+	good := createTestFeed(t, s, user, "Gators", server.URL+"/good")
+	broken := createTestFeed(t, s, user, "Broken", server.URL+"/broken")
+	_, err := s.conn.Exec("UPDATE feeds SET created_at = $1", time.Now().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, feed := range []string{good.Url, broken.Url} {
+		f, err := s.db.GetFeedByUrl(context.Background(), feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := scrapeFeed(s, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Both were fetched just now and neither is due, but the broken feed
+	//hasn't succeeded since it was added
+	if err := updateStalestFeedAge(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := metricValue(t, "gator_stalest_feed_age_seconds"); got < (2 * time.Hour).Seconds() {
+		t.Errorf("age = %v, want at least two hours", got)
+	}
+
+	_, err = s.conn.Exec("DELETE FROM feeds WHERE id = $1", broken.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updateStalestFeedAge(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := metricValue(t, "gator_stalest_feed_age_seconds"); got > time.Minute.Seconds() {
+		t.Errorf("age with only the good feed = %v, want under a minute", got)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+// setPostRead marks a post read or unread for a user.
+func setPostRead(ctx context.Context, s *state, userID, postID uuid.UUID, read bool) error {
+	now := time.Now()
+	args := database.SetPostReadParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    userID,
+		PostID:    postID,
+		Read:      read,
+	}
+	if err := s.db.SetPostRead(ctx, args); err != nil {
+		return fmt.Errorf("unable to mark post read: %w", err)
+	}
+	return nil
+}
+
+// handlerOpen opens a post in the browser and marks it read.
+func handlerOpen(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("open expects 1 argument: post ID, position or url")
+	}
+
+	post, err := findPost(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	//the url came from a feed, so hand nothing but a web link to the opener
+	if u, err := url.Parse(post.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("refusing to open %s", post.Url)
+	}
+
+	if err := openURL(post.Url); err != nil {
+		return err
+	}
+
+	return setPostRead(context.Background(), s, user.ID, post.ID, true)
+}
+
+// openURL runs $BROWSER, a colon separated list of commands where %s stands
+// for the link, falling back to the system's opener.
+func openURL(link string) error {
+	if browsers := os.Getenv("BROWSER"); browsers != "" {
+		var errs []string
+		for _, browser := range strings.Split(browsers, ":") {
+			fields := strings.Fields(browser)
+			if len(fields) == 0 {
+				continue
+			}
+			args := fields[1:]
+			if strings.Contains(browser, "%s") {
+				for i, arg := range args {
+					args[i] = strings.ReplaceAll(arg, "%s", link)
+				}
+			} else {
+				args = append(args, link)
+			}
+			if err := exec.Command(fields[0], args...).Start(); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			return nil
+		}
+		return fmt.Errorf("unable to run $BROWSER: %s", strings.Join(errs, "; "))
+	}
+
+	var opener *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		opener = exec.Command("open", link)
+	case "windows":
+		opener = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		opener = exec.Command("xdg-open", link)
+	}
+	if err := opener.Start(); err != nil {
+		return fmt.Errorf("unable to open browser: %w", err)
+	}
+	return nil
+}
+
+// handlerShow pipes a rendered post through $PAGER and marks it read.
+func handlerShow(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("show expects 1 argument: post ID, position or url")
+	}
+
+	post, err := findPost(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	writePost(&rendered, post, terminalWidth())
+	if err := page(rendered.Bytes()); err != nil {
+		return err
+	}
+
+	return setPostRead(context.Background(), s, user.ID, post.ID, true)
+}
+
+// page shows text through $PAGER, or less, or just prints it when stdout
+// isn't a terminal.
+func page(text []byte) error {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		_, err := os.Stdout.Write(text)
+		return err
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+		if runtime.GOOS == "windows" {
+			pager = "more"
+		}
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/c", pager)
+	} else {
+		//$PAGER may carry its own flags
+		cmd = exec.Command("sh", "-c", pager)
+	}
+	cmd.Stdin = bytes.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("unable to run pager %s: %w", pager, err)
+	}
+	return nil
+}
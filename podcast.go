@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const (
+	defaultKeepLast = 5
+
+	// downloadIdleTimeout gives up on an enclosure once its server has sent
+	// nothing for this long, however long the whole download takes.
+	downloadIdleTimeout = 60 * time.Second
+)
+
+var errDownloadStalled = errors.New("download stalled")
+
+// downloadClient fetches enclosures, which can take far longer than a feed,
+// so the body is bounded by downloadIdleTimeout rather than a total limit.
+var downloadClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: feedConnectTimeout}).DialContext,
+		TLSHandshakeTimeout:   feedConnectTimeout,
+		ResponseHeaderTimeout: feedHeaderTimeout,
+	},
+}
+
+type RSSEnclosure struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type RSSMediaContent struct {
+	Url      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Medium   string `xml:"medium,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// itemEnclosure is an enclosure or media:content merged with the item's
+// iTunes episode details.
+type itemEnclosure struct {
+	Url      string
+	Type     string
+	Length   string
+	Duration string
+}
+
+// enclosures lists an item's <enclosure>s followed by any audio or video
+// media:content that doesn't repeat one of them.
+func (item RSSItem) enclosures() []itemEnclosure {
+	var all []itemEnclosure
+	seen := map[string]bool{}
+
+	for _, enc := range item.Enclosures {
+		if enc.Url == "" || seen[enc.Url] {
+			continue
+		}
+		seen[enc.Url] = true
+		all = append(all, itemEnclosure{Url: enc.Url, Type: enc.Type, Length: enc.Length, Duration: item.ItunesDuration})
+	}
+
+	for _, media := range item.MediaContent {
+		if media.Url == "" || seen[media.Url] {
+			continue
+		}
+		isAV := media.Medium == "audio" || media.Medium == "video" ||
+			strings.HasPrefix(media.Type, "audio/") || strings.HasPrefix(media.Type, "video/")
+		if !isAV {
+			continue
+		}
+		seen[media.Url] = true
+
+		duration := media.Duration
+		if duration == "" {
+			duration = item.ItunesDuration
+		}
+		all = append(all, itemEnclosure{Url: media.Url, Type: media.Type, Length: media.FileSize, Duration: duration})
+	}
+
+	return all
+}
+
+// saveEnclosures stores the enclosures of a freshly inserted post.
+func saveEnclosures(s *state, logger *slog.Logger, post database.Post, item RSSItem) {
+	for _, enc := range item.enclosures() {
+		now := time.Now()
+		args := database.CreateEnclosureParams{
+			ID:              uuid.New(),
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			PostID:          post.ID,
+			Url:             enc.Url,
+			MimeType:        sql.NullString{String: enc.Type, Valid: enc.Type != ""},
+			Length:          parseNullInt64(enc.Length),
+			DurationSeconds: parseDuration(enc.Duration),
+			Episode:         parseNullInt32(item.ItunesEpisode),
+			Season:          parseNullInt32(item.ItunesSeason),
+		}
+		if err := s.db.CreateEnclosure(context.Background(), args); err != nil {
+			logger.Error("unable to create enclosure", "post_url", post.Url, "enclosure_url", enc.Url, "error", err)
+		}
+	}
+}
+
+func parseNullInt64(value string) sql.NullInt64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: n, Valid: true}
+}
+
+func parseNullInt32(value string) sql.NullInt32 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	if err != nil || n <= 0 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(n), Valid: true}
+}
+
+// parseDuration reads itunes:duration, which is either plain seconds or
+// [[HH:]MM:]SS.
+func parseDuration(value string) sql.NullInt32 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return sql.NullInt32{}
+	}
+
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(strings.Split(part, ".")[0])
+		if err != nil || n < 0 {
+			return sql.NullInt32{}
+		}
+		seconds = seconds*60 + n
+	}
+	if seconds == 0 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(seconds), Valid: true}
+}
+
+// describeEnclosure is how browse shows an enclosure.
+func describeEnclosure(enc database.Enclosure) string {
+	var details []string
+	if enc.MimeType.Valid {
+		details = append(details, enc.MimeType.String)
+	}
+	if enc.DurationSeconds.Valid {
+		details = append(details, (time.Duration(enc.DurationSeconds.Int32) * time.Second).String())
+	}
+	if enc.Length.Valid {
+		details = append(details, fmt.Sprintf("%.1f MB", float64(enc.Length.Int64)/(1<<20)))
+	}
+	switch {
+	case enc.Season.Valid && enc.Episode.Valid:
+		details = append(details, fmt.Sprintf("S%dE%d", enc.Season.Int32, enc.Episode.Int32))
+	case enc.Episode.Valid:
+		details = append(details, fmt.Sprintf("episode %d", enc.Episode.Int32))
+	}
+
+	if len(details) == 0 {
+		return enc.Url
+	}
+	return fmt.Sprintf("%s (%s)", enc.Url, strings.Join(details, ", "))
+}
+
+// handlerDownload fetches the newest enclosures of every followed feed into
+// a directory per feed, keeping only the last N of each.
+func handlerDownload(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 || len(cmd.args) > 2 {
+		return fmt.Errorf("download expects 1 or 2 arguments: dir [keep_last]")
+	}
+	dir := cmd.args[0]
+
+	keepLast := defaultKeepLast
+	if len(cmd.args) == 2 {
+		n, err := strconv.Atoi(cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("unable to parse keep_last: %s [%w]", cmd.args[1], err)
+		}
+		if n < 1 {
+			return fmt.Errorf("keep_last must be at least 1")
+		}
+		keepLast = n
+	}
+
+	enclosures, err := s.db.GetEnclosuresForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("unable to get enclosures for user: %w", err)
+	}
+
+	return downloadEnclosures(s, dir, enclosures, keepLast)
+}
+
+// downloadEnclosures downloads the newest keepLast enclosures of each feed
+// and removes the older ones it downloaded before.
+func downloadEnclosures(s *state, dir string, enclosures []database.GetEnclosuresForUserRow, keepLast int) error {
+	//keep_last counts per feed, but feeds whose names clean up the same
+	//share a directory, so nothing is removed until every feed is done
+	var dirs []*enclosureDir
+	byPath := map[string]*enclosureDir{}
+
+	//Rows come grouped by feed, newest first
+	var feedEnclosures []database.GetEnclosuresForUserRow
+	for i, enc := range enclosures {
+		feedEnclosures = append(feedEnclosures, enc)
+		if i+1 < len(enclosures) && enclosures[i+1].FeedID == enc.FeedID {
+			continue
+		}
+
+		feedDir := filepath.Join(dir, safeFileName(enc.FeedName))
+		d, ok := byPath[feedDir]
+		if !ok {
+			d = &enclosureDir{path: feedDir, known: map[string]bool{}, keep: map[string]bool{}}
+			byPath[feedDir] = d
+			dirs = append(dirs, d)
+		}
+		if err := downloadFeedEnclosures(s, d, feedEnclosures, keepLast); err != nil {
+			return err
+		}
+		feedEnclosures = nil
+	}
+
+	for _, d := range dirs {
+		if err := cleanEnclosureDir(s, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enclosureDir is a download directory with the files gator knows it may
+// have put there and the ones still wanted.
+type enclosureDir struct {
+	path  string
+	known map[string]bool
+	keep  map[string]bool
+}
+
+// downloadFeedEnclosures downloads the newest keepLast enclosures of one
+// feed into d.
+func downloadFeedEnclosures(s *state, d *enclosureDir, enclosures []database.GetEnclosuresForUserRow, keepLast int) error {
+	if err := os.MkdirAll(d.path, 0755); err != nil {
+		return fmt.Errorf("unable to create directory: %w", err)
+	}
+	logger := s.logger.With("feed_id", enclosures[0].FeedID, "dir", d.path)
+
+	for i, enc := range enclosures {
+		fileName := enclosureFileName(enc)
+		d.known[fileName] = true
+		d.known[fileName+".part"] = true
+		if i >= keepLast {
+			continue
+		}
+		d.keep[fileName] = true
+		d.keep[fileName+".part"] = true
+
+		target := filepath.Join(d.path, fileName)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+
+		fmt.Printf("downloading %s\n", target)
+		if err := downloadFile(context.Background(), enc.Url, target, downloadIdleTimeout); err != nil {
+			logger.Error("unable to download enclosure", "url", enc.Url, "error", err)
+			continue
+		}
+	}
+
+	return nil
+}
+
+// cleanEnclosureDir removes the files of enclosures that are no longer among
+// the newest of their feed. Only files named after a stored enclosure are
+// touched, anything else in there is left alone.
+func cleanEnclosureDir(s *state, d *enclosureDir) error {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return fmt.Errorf("unable to read directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !d.known[entry.Name()] || d.keep[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.path, entry.Name())); err != nil {
+			s.logger.Warn("unable to remove old enclosure", "dir", d.path, "file", entry.Name(), "error", err)
+			continue
+		}
+		fmt.Printf("removed %s\n", filepath.Join(d.path, entry.Name()))
+	}
+
+	return nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\pL\pN._ -]+`)
+
+// enclosureFileName is "<post seq>-<position>-<name>" so files sort by post
+// and enclosures of one post don't overwrite each other.
+func enclosureFileName(enc database.GetEnclosuresForUserRow) string {
+	name := ""
+	if u, err := url.Parse(enc.Url); err == nil {
+		name = path.Base(u.Path)
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "enclosure"
+	}
+
+	if filepath.Ext(name) == "" && enc.MimeType.Valid {
+		if exts, err := mime.ExtensionsByType(enc.MimeType.String); err == nil && len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+
+	return fmt.Sprintf("%d-%d-%s", enc.PostSeq, enc.Position, safeFileName(name))
+}
+
+func safeFileName(name string) string {
+	//no leading dots, so a name can't be hidden or climb out with ".."
+	name = strings.TrimLeft(strings.TrimSpace(unsafeFileChars.ReplaceAllString(name, "_")), ".")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// downloadFile writes url to target through a .part file, picking up where
+// an interrupted download left off when the server supports ranges. It gives
+// up once idle passes without any data arriving.
+func downloadFile(ctx context.Context, fileURL, target string, idle time.Duration) error {
+	partial := target + ".part"
+
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	downloadCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stall := time.AfterFunc(idle, func() { cancel(errDownloadStalled) })
+	defer stall.Stop()
+
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", fileURL, nil)
+	if err != nil {
+		return fmt.Errorf("unable to make new request: %w", err)
+	}
+	req.Header.Set("User-Agent", "gator")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		//Only a partial file that is already complete can't be resumed
+		if total, ok := rangeTotal(resp.Header.Get("Content-Range")); ok && total == offset {
+			return os.Rename(partial, target)
+		}
+		//Anything else is stale, so start over without it
+		if err := os.Remove(partial); err != nil {
+			return fmt.Errorf("unable to remove partial file: %w", err)
+		}
+		return downloadFile(ctx, fileURL, target, idle)
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		//the server ignored the range, so start over
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open file: %w", err)
+	}
+	_, copyErr := io.Copy(file, &progressReader{r: resp.Body, onRead: func() { stall.Reset(idle) }})
+	closeErr := file.Close()
+	if errors.Is(context.Cause(downloadCtx), errDownloadStalled) {
+		copyErr = errDownloadStalled
+	}
+	if err := errors.Join(copyErr, closeErr); err != nil {
+		return fmt.Errorf("unable to write file: %w", err)
+	}
+
+	return os.Rename(partial, target)
+}
+
+// progressReader calls onRead whenever a read returns data.
+type progressReader struct {
+	r      io.Reader
+	onRead func()
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.onRead()
+	}
+	return n, err
+}
+
+// rangeTotal reads the complete length from a "bytes */1234" or
+// "bytes 0-99/1234" Content-Range.
+func rangeTotal(contentRange string) (int64, bool) {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok || !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
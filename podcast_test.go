@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+func TestDownloadEnclosures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "audio for "+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	s := &state{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	dir := t.TempDir()
+	feedDir := filepath.Join(dir, "Swamp Talk")
+
+	//Two feeds that happen to share a name, and so a directory
+	first, second := uuid.New(), uuid.New()
+	enclosure := func(feedID uuid.UUID, seq int64, position int32, name string) database.GetEnclosuresForUserRow {
+		return database.GetEnclosuresForUserRow{
+			Url:      server.URL + "/" + name,
+			PostSeq:  seq,
+			Position: position,
+			FeedID:   feedID,
+			FeedName: "Swamp Talk",
+		}
+	}
+	//Rows come grouped by feed, newest first
+	enclosures := []database.GetEnclosuresForUserRow{
+		enclosure(first, 13, 1, "episode.mp3"), enclosure(first, 12, 1, "episode.mp3"), enclosure(first, 11, 1, "episode.mp3"),
+		//one post with two files of the same name
+		enclosure(second, 23, 1, "a/episode.mp3"), enclosure(second, 23, 2, "b/episode.mp3"), enclosure(second, 22, 1, "episode.mp3"),
+	}
+
+	//Files from earlier runs, and some that were never gator's
+	if err := os.MkdirAll(feedDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"11-1-episode.mp3", "22-1-episode.mp3.part", "2024-notes.txt", "99-1-episode.mp3"} {
+		if err := os.WriteFile(filepath.Join(feedDir, name), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := downloadEnclosures(s, dir, enclosures, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(feedDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{"12-1-episode.mp3", "13-1-episode.mp3", "2024-notes.txt", "23-1-episode.mp3", "23-2-episode.mp3", "99-1-episode.mp3"}
+	if !slices.Equal(names, want) {
+		t.Errorf("files = %q, want %q", names, want)
+	}
+
+	for name, want := range map[string]string{
+		"23-1-episode.mp3": "audio for /a/episode.mp3",
+		"23-2-episode.mp3": "audio for /b/episode.mp3",
+	} {
+		got, err := os.ReadFile(filepath.Join(feedDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s holds %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDownloadFileStalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "the first bytes")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	target := filepath.Join(t.TempDir(), "episode.mp3")
+	err := downloadFile(context.Background(), server.URL, target, 100*time.Millisecond)
+	if !errors.Is(err, errDownloadStalled) {
+		t.Fatalf("err = %v, want %v", err, errDownloadStalled)
+	}
+
+	//What did arrive is kept to resume from
+	got, err := os.ReadFile(target + ".part")
+	if err != nil || string(got) != "the first bytes" {
+		t.Errorf("partial file = %q, %v", got, err)
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Error("a stalled download was renamed into place")
+	}
+}
+
+func TestDownloadFileRangeNotSatisfiable(t *testing.T) {
+	const content = "the whole episode"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(content)))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(server.Close)
+
+	for _, partial := range []string{
+		content,
+		//longer than the file, so it can't be part of it
+		content + " and then some",
+	} {
+		target := filepath.Join(t.TempDir(), "episode.mp3")
+		if err := os.WriteFile(target+".part", []byte(partial), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := downloadFile(context.Background(), server.URL, target, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(target)
+		if err != nil || string(got) != content {
+			t.Errorf("from partial %q: file = %q, %v", partial, got, err)
+		}
+	}
+}
+
+func TestRangeTotal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"bytes */1234", 1234, true},
+		{"bytes 0-99/1234", 1234, true},
+		{"bytes 0-99/*", 0, false},
+		{"", 0, false},
+		{"items */5", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := rangeTotal(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("rangeTotal(%q) = %d, %t, want %d, %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
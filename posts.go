@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/rules"
+	"github.com/kbm-ky/gator/internal/termtext"
+)
+
+// RSSAuthor is an RSS <author>, usually an email address, or an Atom
+// <author> with the name in a child element.
+type RSSAuthor struct {
+	Text string `xml:",chardata"`
+	Name string `xml:"name"`
+}
+
+// RSSCategory is an RSS <category> or an Atom <category term="...">.
+type RSSCategory struct {
+	Text string `xml:",chardata"`
+	Term string `xml:"term,attr"`
+}
+
+// content is the item's full content, preferring content:encoded.
+func (item RSSItem) content() string {
+	if content := strings.TrimSpace(item.ContentEncoded); content != "" {
+		return content
+	}
+	return strings.TrimSpace(item.AtomContent)
+}
+
+// author prefers a display name over an RSS author's email address.
+func (item RSSItem) author() string {
+	for _, author := range []string{item.Author.Name, item.DcCreator, item.Author.Text} {
+		if author = strings.TrimSpace(author); author != "" {
+			return author
+		}
+	}
+	return ""
+}
+
+func (item RSSItem) categories() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, category := range item.Categories {
+		name := strings.TrimSpace(category.Term)
+		if name == "" {
+			name = strings.TrimSpace(category.Text)
+		}
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// commentsURL is the item's <comments> link, if it is an absolute URL.
+func (item RSSItem) commentsURL() string {
+	u, err := url.Parse(strings.TrimSpace(item.Comments))
+	if err != nil || !u.IsAbs() {
+		return ""
+	}
+	return u.String()
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// savePostCategories stores the categories of a freshly inserted post.
+func savePostCategories(s *state, logger *slog.Logger, post database.Post, item RSSItem) {
+	for _, name := range item.categories() {
+		now := time.Now()
+		args := database.CreatePostCategoryParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			PostID:    post.ID,
+			Name:      name,
+		}
+		if err := s.db.CreatePostCategory(context.Background(), args); err != nil {
+			logger.Error("unable to create post category", "post_url", post.Url, "category", name, "error", err)
+		}
+	}
+}
+
+// postFilters narrow browse and search down to an author, a category or
+// the feeds with one of the user's tags.
+type postFilters struct {
+	Author   sql.NullString
+	Category sql.NullString
+	Tag      sql.NullString
+}
+
+// parsePostFilters reads --author, --category and --tag off the front of
+// args and returns the rest.
+func parsePostFilters(name string, args []string) (postFilters, []string, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	author := flags.String("author", "", "only posts by this author")
+	category := flags.String("category", "", "only posts in this category")
+	tag := flags.String("tag", "", "only posts from feeds with this tag")
+	if err := flags.Parse(args); err != nil {
+		return postFilters{}, nil, fmt.Errorf("unable to parse %s flags: %w", name, err)
+	}
+
+	filters := postFilters{
+		Author:   nullString(strings.TrimSpace(*author)),
+		Category: nullString(strings.TrimSpace(*category)),
+		Tag:      nullString(normalizeTag(*tag)),
+	}
+	return filters, flags.Args(), nil
+}
+
+func parseLimit(args []string) (int64, error) {
+	limitStr := "2"
+	if len(args) > 0 {
+		limitStr = args[0]
+	}
+
+	limit, err := strconv.ParseInt(limitStr, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse limit: %s [%w]", limitStr, err)
+	}
+	return limit, nil
+}
+
+func handlerSearch(s *state, cmd command, user database.User) error {
+	filters, args, err := parsePostFilters(cmd.name, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("search expects 1 or 2 arguments: [--author name] [--category name] [--tag name] query [limit]")
+	}
+	query := args[0]
+
+	limit, err := parseLimit(args[1:])
+	if err != nil {
+		return err
+	}
+	if limit < 1 {
+		return fmt.Errorf("limit must be at least 1")
+	}
+
+	userRules, err := loadRules(s, user)
+	if err != nil {
+		return fmt.Errorf("unable to load rules: %w", err)
+	}
+
+	shown := int64(0)
+	for offset := int64(0); shown < limit; offset += limit {
+		searchArgs := database.SearchPostsForUserParams{
+			UserID:   user.ID,
+			Limit:    int32(limit),
+			Offset:   int32(offset),
+			Query:    query,
+			Author:   filters.Author,
+			Category: filters.Category,
+			Tag:      filters.Tag,
+		}
+
+		posts, err := s.db.SearchPostsForUser(context.Background(), searchArgs)
+		if err != nil {
+			return fmt.Errorf("unable to search posts for user: %w", err)
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		for _, post := range posts {
+			printed, err := printPost(s, post, userRules)
+			if err != nil {
+				return err
+			}
+			if !printed {
+				continue
+			}
+
+			shown++
+			if shown == limit {
+				break
+			}
+		}
+	}
+
+	if shown == 0 {
+		fmt.Printf("no posts match %q\n", query)
+	}
+
+	return nil
+}
+
+// printPost shows a post the way browse and search do, unless a rule mutes
+// it. It reports whether the post was shown.
+func printPost(s *state, post database.Post, userRules []rules.Rule) (bool, error) {
+	muted, highlighted := rules.Evaluate(userRules, post.FeedID, post.Title.String, post.Description.String)
+	if muted {
+		return false, nil
+	}
+
+	fmt.Printf("ID: p%d\n", post.Seq)
+	if highlighted {
+		fmt.Printf("Title: ★ %s\n", termtext.Clean(post.Title.String))
+	} else {
+		fmt.Printf("Title: %s\n", termtext.Clean(post.Title.String))
+	}
+	fmt.Printf("Url: %s\n", termtext.Clean(post.Url))
+	if post.Author.Valid {
+		fmt.Printf("Author: %s\n", termtext.Clean(post.Author.String))
+	}
+
+	categories, err := s.db.GetCategoriesForPost(context.Background(), post.ID)
+	if err != nil {
+		return false, fmt.Errorf("unable to get categories for post: %w", err)
+	}
+	if len(categories) > 0 {
+		fmt.Printf("Categories: %s\n", termtext.Clean(strings.Join(categories, ", ")))
+	}
+	if post.CommentsUrl.Valid {
+		fmt.Printf("Comments: %s\n", termtext.Clean(post.CommentsUrl.String))
+	}
+	if description := termtext.Render(post.Description.String, terminalWidth()); description != "" {
+		fmt.Printf("Description:\n%s\n", description)
+	}
+
+	enclosures, err := s.db.GetEnclosuresForPost(context.Background(), post.ID)
+	if err != nil {
+		return false, fmt.Errorf("unable to get enclosures for post: %w", err)
+	}
+	for _, enc := range enclosures {
+		fmt.Printf("Enclosure: %s\n", termtext.Clean(describeEnclosure(enc)))
+	}
+	fmt.Println()
+
+	return true, nil
+}
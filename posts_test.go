@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+func TestPostOrderWeighsPriority(t *testing.T) {
+	s := newTestState(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "alice")
+	plain := createTestFeed(t, s, user, "Plain", "https://plain.example/feed")
+	favourite := createTestFeed(t, s, user, "Favourite", "https://favourite.example/feed")
+	err := s.db.SetFeedFollowPriority(ctx, database.SetFeedFollowPriorityParams{
+		UserID:    user.ID,
+		FeedID:    favourite.ID,
+		Priority:  2,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	day := 24 * time.Hour
+	createTestPost(t, s, plain, "https://plain.example/today", now)
+	createTestPost(t, s, plain, "https://plain.example/yesterday", now.Add(-day))
+	createTestPost(t, s, favourite, "https://favourite.example/yesterday", now.Add(-day))
+	createTestPost(t, s, favourite, "https://favourite.example/4-days", now.Add(-4*day))
+
+	//Two points of priority are worth two days, enough to beat today's plain
+	//post with yesterday's but not with one from four days ago
+	want := []string{
+		"https://favourite.example/yesterday",
+		"https://plain.example/today",
+		"https://plain.example/yesterday",
+		"https://favourite.example/4-days",
+	}
+
+	posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: user.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := postURLs(posts); !slices.Equal(got, want) {
+		t.Errorf("browse order = %q, want %q", got, want)
+	}
+
+	posts, err = s.db.SearchPostsForUser(ctx, database.SearchPostsForUserParams{UserID: user.ID, Query: "Post at", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := postURLs(posts); !slices.Equal(got, want) {
+		t.Errorf("search order = %q, want %q", got, want)
+	}
+}
+
+func postURLs(posts []database.Post) []string {
+	var urls []string
+	for _, post := range posts {
+		urls = append(urls, post.Url)
+	}
+	return urls
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/sanitize"
+)
+
+const defaultPublishLimit = 50
+
+type publishedFeed struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	Channel publishedChannel `xml:"channel"`
+}
+
+type publishedChannel struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Description   string          `xml:"description"`
+	LastBuildDate string          `xml:"lastBuildDate"`
+	Generator     string          `xml:"generator"`
+	Item          []publishedItem `xml:"item"`
+}
+
+type publishedItem struct {
+	Title       string          `xml:"title,omitempty"`
+	Link        string          `xml:"link"`
+	Description string          `xml:"description,omitempty"`
+	PubDate     string          `xml:"pubDate"`
+	GUID        publishedGUID   `xml:"guid"`
+	Source      publishedSource `xml:"source"`
+}
+
+type publishedGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type publishedSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+func handlerPublish(s *state, cmd command, user database.User) error {
+	//check args
+	if len(cmd.args) < 1 || len(cmd.args) > 2 {
+		return fmt.Errorf("publish expects 1 or 2 arguments: file [limit]")
+	}
+	fileName := cmd.args[0]
+
+	limit := defaultPublishLimit
+	if len(cmd.args) == 2 {
+		n, err := strconv.Atoi(cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("unable to parse limit: %s [%w]", cmd.args[1], err)
+		}
+		if n < 1 {
+			return fmt.Errorf("publish expects a limit of at least 1, got %d", n)
+		}
+		limit = n
+	}
+
+	xmlBlob, err := renderTimeline(context.Background(), s, user, limit)
+	if err != nil {
+		return fmt.Errorf("unable to render timeline: %w", err)
+	}
+
+	if err := os.WriteFile(fileName, xmlBlob, 0666); err != nil {
+		return fmt.Errorf("unable to write feed: %w", err)
+	}
+
+	fmt.Printf("wrote timeline for %s to %s\n", user.Name, fileName)
+
+	return nil
+}
+
+func handlerFeedToken(s *state, cmd command, user database.User) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("unable to generate token: %w", err)
+	}
+
+	now := time.Now()
+	args := database.UpsertFeedTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Token:     hex.EncodeToString(raw),
+	}
+	feedToken, err := s.db.UpsertFeedToken(context.Background(), args)
+	if err != nil {
+		return fmt.Errorf("unable to save feed token: %w", err)
+	}
+
+	fmt.Printf("feed token for %s has been set, any previous URL no longer works\n", user.Name)
+	fmt.Printf("URL path: /rss/%s\n", feedToken.Token)
+
+	return nil
+}
+
+func registerPublishRoutes(s *state, mux *http.ServeMux) {
+	mux.HandleFunc("GET /rss/{token}", func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.db.GetUserByFeedToken(r.Context(), r.PathValue("token"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		xmlBlob, err := renderTimeline(r.Context(), s, user, defaultPublishLimit)
+		if err != nil {
+			s.logger.Error("unable to render timeline", "user", user.Name, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write(xmlBlob)
+	})
+}
+
+// renderTimeline builds an RSS 2.0 document out of the newest posts from
+// every feed the user follows.
+func renderTimeline(ctx context.Context, s *state, user database.User, limit int) ([]byte, error) {
+	args := database.GetTimelineForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	}
+	posts, err := s.db.GetTimelineForUser(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get timeline for user: %w", err)
+	}
+
+	feed := publishedFeed{
+		Version: "2.0",
+		Channel: publishedChannel{
+			Title:         fmt.Sprintf("gator: %s", user.Name),
+			Link:          "https://github.com/kbm-ky/gator",
+			Description:   fmt.Sprintf("Posts from every feed %s is following", user.Name),
+			LastBuildDate: time.Now().Format(time.RFC1123Z),
+			Generator:     "gator",
+		},
+	}
+
+	for _, post := range posts {
+		item := publishedItem{
+			Title:       post.Title.String,
+			Link:        post.Url,
+			Description: sanitize.HTML(post.Description.String),
+			PubDate:     postTime(post.PublishedAt, post.CreatedAt).Format(time.RFC1123Z),
+			GUID: publishedGUID{
+				IsPermaLink: false,
+				Value:       "urn:uuid:" + post.ID.String(),
+			},
+			Source: publishedSource{
+				URL:  post.FeedUrl,
+				Name: post.FeedName,
+			},
+		}
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+
+	xmlBlob, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal xml: %w", err)
+	}
+
+	return append([]byte(xml.Header), xmlBlob...), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const maxRedirects = 10
+
+type redirectChainKey struct{}
+
+// redirectChain records the hops feedClient followed for one request.
+type redirectChain struct {
+	hops []redirectHop
+}
+
+type redirectHop struct {
+	Status int
+	To     string
+}
+
+// permanentURL is where the feed now lives according to the unbroken run of
+// 301/308 redirects at the start of the chain, or "" if it hasn't moved.
+func (c *redirectChain) permanentURL() string {
+	moved := ""
+	for _, hop := range c.hops {
+		if hop.Status != http.StatusMovedPermanently && hop.Status != http.StatusPermanentRedirect {
+			break
+		}
+		moved = hop.To
+	}
+	return moved
+}
+
+// trackRedirects is feedClient's CheckRedirect, noting each hop on the
+// redirectChain carried by the request context.
+func trackRedirects(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	if chain, ok := req.Context().Value(redirectChainKey{}).(*redirectChain); ok && req.Response != nil {
+		chain.hops = append(chain.hops, redirectHop{
+			Status: req.Response.StatusCode,
+			To:     req.URL.String(),
+		})
+	}
+
+	return nil
+}
+
+// inTx runs fn with queries bound to a transaction, committing only if fn
+// succeeds.
+func inTx(ctx context.Context, s *state, fn func(q *database.Queries) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(s.db.WithTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// moveFeed points a feed at the URL it permanently redirected to, keeping
+// the old URL as an alias. If another feed already has the new URL the two
+// are merged into that one, follows, posts, webhooks and rules included.
+// It returns the feed that now owns newURL.
+func moveFeed(ctx context.Context, s *state, feed database.Feed, newURL string) (database.Feed, error) {
+	var target database.Feed
+	err := inTx(ctx, s, func(q *database.Queries) error {
+		now := time.Now()
+
+		var err error
+		target, err = q.GetFeedByUrl(ctx, newURL)
+		switch {
+		case errors.Is(err, sql.ErrNoRows) || (err == nil && target.ID == feed.ID):
+			//nobody else has the new URL, so just move this feed over
+			if err := q.DeleteFeedAliasByUrl(ctx, newURL); err != nil {
+				return fmt.Errorf("unable to delete feed alias: %w", err)
+			}
+			args := database.UpdateFeedUrlParams{
+				ID:        feed.ID,
+				Url:       newURL,
+				UpdatedAt: now,
+			}
+			if err := q.UpdateFeedUrl(ctx, args); err != nil {
+				return fmt.Errorf("unable to update feed url: %w", err)
+			}
+			target = feed
+			target.Url = newURL
+			target.UpdatedAt = now
+		case err != nil:
+			return fmt.Errorf("unable to get feed by url: %w", err)
+		default:
+			if err := mergeFeeds(ctx, q, feed, target, now); err != nil {
+				return err
+			}
+		}
+
+		aliasArgs := database.CreateFeedAliasParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			FeedID:    target.ID,
+			Url:       feed.Url,
+		}
+		if err := q.CreateFeedAlias(ctx, aliasArgs); err != nil {
+			return fmt.Errorf("unable to create feed alias: %w", err)
+		}
+
+		return nil
+	})
+	return target, err
+}
+
+// mergeFeeds moves everything hanging off from onto into and deletes from.
+// Users already following into keep that follow, with the tags of their
+// follow of from added and its title and priority filling in any it lacks.
+func mergeFeeds(ctx context.Context, q *database.Queries, from, into database.Feed, now time.Time) error {
+	if err := q.MergeFeedFollowTags(ctx, database.MergeFeedFollowTagsParams{Now: now, FromFeedID: from.ID, ToFeedID: into.ID}); err != nil {
+		return fmt.Errorf("unable to merge feed follow tags: %w", err)
+	}
+	if err := q.MergeFeedFollowPreferences(ctx, database.MergeFeedFollowPreferencesParams{UpdatedAt: now, ToFeedID: into.ID, FromFeedID: from.ID}); err != nil {
+		return fmt.Errorf("unable to merge feed follow preferences: %w", err)
+	}
+	if err := q.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{ToFeedID: into.ID, UpdatedAt: now, FromFeedID: from.ID}); err != nil {
+		return fmt.Errorf("unable to move feed follows: %w", err)
+	}
+	if err := q.MovePosts(ctx, database.MovePostsParams{ToFeedID: into.ID, UpdatedAt: now, FromFeedID: from.ID}); err != nil {
+		return fmt.Errorf("unable to move posts: %w", err)
+	}
+	if err := q.MoveWebhooks(ctx, database.MoveWebhooksParams{ToFeedID: into.ID, UpdatedAt: now, FromFeedID: from.ID}); err != nil {
+		return fmt.Errorf("unable to move webhooks: %w", err)
+	}
+	if err := q.MoveRules(ctx, database.MoveRulesParams{ToFeedID: into.ID, UpdatedAt: now, FromFeedID: from.ID}); err != nil {
+		return fmt.Errorf("unable to move rules: %w", err)
+	}
+	if err := q.MoveFeedAliases(ctx, database.MoveFeedAliasesParams{ToFeedID: into.ID, UpdatedAt: now, FromFeedID: from.ID}); err != nil {
+		return fmt.Errorf("unable to move feed aliases: %w", err)
+	}
+
+	if err := q.DeleteFeed(ctx, from.ID); err != nil {
+		return fmt.Errorf("unable to delete feed: %w", err)
+	}
+	return nil
+}
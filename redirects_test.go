@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+func tagFollow(t *testing.T, s *state, user database.User, feed database.Feed, name string) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+
+	tag, err := s.db.UpsertTag(ctx, database.UpsertTagParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: user.ID, Name: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	follow, err := s.db.GetFeedFollowForUser(ctx, database.GetFeedFollowForUserParams{UserID: user.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.db.AddFeedFollowTag(ctx, database.AddFeedFollowTagParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, FeedFollowID: follow.ID, TagID: tag.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveFeedMergesFollows(t *testing.T) {
+	s := newTestState(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	old := createTestFeed(t, s, alice, "Old", "https://old.example/feed")
+	moved := createTestFeed(t, s, alice, "New", "https://new.example/feed")
+	if _, err := followFeed(ctx, s, bob, old); err != nil {
+		t.Fatal(err)
+	}
+
+	//alice set everything up on the old follow, and one tag on the new one
+	now := time.Now()
+	if err := s.db.SetFeedFollowTitle(ctx, database.SetFeedFollowTitleParams{UserID: alice.ID, FeedID: old.ID, Title: nullString("Swamp News"), UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.SetFeedFollowPriority(ctx, database.SetFeedFollowPriorityParams{UserID: alice.ID, FeedID: old.ID, Priority: 3, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	tagFollow(t, s, alice, old, "reptiles")
+	tagFollow(t, s, alice, old, "daily")
+	tagFollow(t, s, alice, moved, "daily")
+	if err := s.db.SetFeedFollowTitle(ctx, database.SetFeedFollowTitleParams{UserID: bob.ID, FeedID: old.ID, Title: nullString("Bob's Swamp"), UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := moveFeed(ctx, s, old, moved.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.ID != moved.ID {
+		t.Fatalf("moved into %s, want %s", target.ID, moved.ID)
+	}
+
+	follow, err := s.db.GetFeedFollowForUser(ctx, database.GetFeedFollowForUserParams{UserID: alice.ID, FeedID: moved.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if follow.Title.String != "Swamp News" || follow.Priority != 3 {
+		t.Errorf("alice's follow has title %q, priority %d", follow.Title.String, follow.Priority)
+	}
+
+	tags, err := s.db.GetFeedTagsForUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tag := range tags {
+		if tag.FeedID != moved.ID {
+			t.Errorf("tag %s is still on feed %s", tag.TagName, tag.FeedID)
+		}
+		names = append(names, tag.TagName)
+	}
+	if !slices.Equal(names, []string{"daily", "reptiles"}) {
+		t.Errorf("alice's tags = %q", names)
+	}
+
+	//bob only followed the old feed, so his follow just moves
+	follow, err = s.db.GetFeedFollowForUser(ctx, database.GetFeedFollowForUserParams{UserID: bob.ID, FeedID: moved.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if follow.Title.String != "Bob's Swamp" {
+		t.Errorf("bob's follow has title %q", follow.Title.String)
+	}
+}
+
+func TestMoveFeedKeepsSurvivorPreferences(t *testing.T) {
+	s := newTestState(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	old := createTestFeed(t, s, alice, "Old", "https://old.example/feed")
+	moved := createTestFeed(t, s, alice, "New", "https://new.example/feed")
+
+	now := time.Now()
+	for feedID, title := range map[uuid.UUID]string{old.ID: "Old Title", moved.ID: "Kept Title"} {
+		if err := s.db.SetFeedFollowTitle(ctx, database.SetFeedFollowTitleParams{UserID: alice.ID, FeedID: feedID, Title: nullString(title), UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for feedID, priority := range map[uuid.UUID]int32{old.ID: 3, moved.ID: -1} {
+		if err := s.db.SetFeedFollowPriority(ctx, database.SetFeedFollowPriorityParams{UserID: alice.ID, FeedID: feedID, Priority: priority, UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := moveFeed(ctx, s, old, moved.Url); err != nil {
+		t.Fatal(err)
+	}
+
+	follow, err := s.db.GetFeedFollowForUser(ctx, database.GetFeedFollowForUserParams{UserID: alice.ID, FeedID: moved.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if follow.Title.String != "Kept Title" || follow.Priority != -1 {
+		t.Errorf("title %q, priority %d, want the surviving follow's own", follow.Title.String, follow.Priority)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/config"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const (
+	// pruneEvery is how often agg --prune runs a pruning pass.
+	pruneEvery = time.Hour
+	// pruneBatchSize bounds how many posts a single delete removes.
+	pruneBatchSize = 500
+)
+
+// retentionPolicy is how long posts are kept and how many are kept per
+// feed. Zero means no limit.
+type retentionPolicy struct {
+	MaxAge   time.Duration
+	MaxPosts int
+}
+
+// globalRetention is the policy for feeds without one of their own, read
+// from retention_max_age and retention_max_posts in the config.
+func globalRetention(cfg *config.Config) (retentionPolicy, error) {
+	var policy retentionPolicy
+
+	if cfg.RetentionMaxAge != "" {
+		d, err := time.ParseDuration(cfg.RetentionMaxAge)
+		if err != nil {
+			return retentionPolicy{}, fmt.Errorf("unable to parse retention_max_age: %s [%w]", cfg.RetentionMaxAge, err)
+		}
+		if d < 0 {
+			return retentionPolicy{}, fmt.Errorf("retention_max_age must not be negative")
+		}
+		policy.MaxAge = d
+	}
+	if cfg.RetentionMaxPosts < 0 {
+		return retentionPolicy{}, fmt.Errorf("retention_max_posts must not be negative")
+	}
+	policy.MaxPosts = cfg.RetentionMaxPosts
+
+	return policy, nil
+}
+
+// prunedFeed is how many posts a pruning pass picked from one feed.
+type prunedFeed struct {
+	Name  string
+	Posts int
+}
+
+// prunePosts finds the posts past their feed's retention policy and, unless
+// dryRun is set, deletes them. Starred posts and posts still in the feed's
+// latest fetch are always kept. It returns the posts picked per feed and
+// how many were actually deleted.
+func prunePosts(ctx context.Context, s *state, dryRun bool) ([]prunedFeed, int64, error) {
+	policy, err := globalRetention(s.cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args := database.GetPrunablePostsParams{
+		MaxAgeSeconds: int32(min(policy.MaxAge/time.Second, math.MaxInt32)),
+		Now:           time.Now(),
+		MaxPosts:      int32(min(policy.MaxPosts, math.MaxInt32)),
+	}
+	rows, err := s.db.GetPrunablePosts(ctx, args)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to get prunable posts: %w", err)
+	}
+
+	//rows come sorted by feed
+	var feeds []prunedFeed
+	ids := make([]uuid.UUID, 0, len(rows))
+	for i, row := range rows {
+		if i == 0 || row.FeedID != rows[i-1].FeedID {
+			feeds = append(feeds, prunedFeed{Name: row.FeedName})
+		}
+		feeds[len(feeds)-1].Posts++
+		ids = append(ids, row.ID)
+	}
+
+	if dryRun {
+		return feeds, 0, nil
+	}
+
+	var deleted int64
+	for start := 0; start < len(ids); start += pruneBatchSize {
+		end := min(start+pruneBatchSize, len(ids))
+		n, err := s.db.DeleteUnstarredPosts(ctx, ids[start:end])
+		if err != nil {
+			return feeds, deleted, fmt.Errorf("unable to delete posts: %w", err)
+		}
+		deleted += n
+	}
+
+	return feeds, deleted, nil
+}
+
+func handlerPrune(s *state, cmd command) error {
+	flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "only report what would be deleted")
+	if err := flags.Parse(cmd.args); err != nil {
+		return fmt.Errorf("unable to parse prune flags: %w", err)
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("prune expects no arguments: [--dry-run]")
+	}
+
+	feeds, deleted, err := prunePosts(context.Background(), s, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	var total int
+	for _, feed := range feeds {
+		fmt.Printf("%s: %d posts\n", feed.Name, feed.Posts)
+		total += feed.Posts
+	}
+
+	if *dryRun {
+		fmt.Printf("would delete %d posts\n", total)
+	} else {
+		fmt.Printf("deleted %d posts\n", deleted)
+	}
+
+	return nil
+}
+
+// parseRetentionValue reads one half of a per-feed policy, where "default"
+// falls back to the global policy.
+func parseRetentionValue(value string, parse func(string) (int32, error)) (sql.NullInt32, error) {
+	if value == "default" {
+		return sql.NullInt32{}, nil
+	}
+	n, err := parse(value)
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	return sql.NullInt32{Int32: n, Valid: true}, nil
+}
+
+func handlerRetention(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 3 {
+		return fmt.Errorf("retention expects 3 arguments: feed_url max_age|default max_posts|default")
+	}
+	feedURL := cmd.args[0]
+
+	maxAge, err := parseRetentionValue(cmd.args[1], func(value string) (int32, error) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse max age: %s [%w]", value, err)
+		}
+		if d < 0 {
+			return 0, fmt.Errorf("max age must not be negative")
+		}
+		return int32(min(d/time.Second, math.MaxInt32)), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	maxPosts, err := parseRetentionValue(cmd.args[2], func(value string) (int32, error) {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse max posts: %s [%w]", value, err)
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("max posts must not be negative")
+		}
+		return int32(n), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("unable to get feed by url: %w", err)
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added %s can change its retention", feed.Url)
+	}
+
+	args := database.SetFeedRetentionParams{
+		ID:                     feed.ID,
+		RetentionMaxAgeSeconds: maxAge,
+		RetentionMaxPosts:      maxPosts,
+		UpdatedAt:              time.Now(),
+	}
+	if err := s.db.SetFeedRetention(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to set retention: %w", err)
+	}
+
+	feed.RetentionMaxAgeSeconds = maxAge
+	feed.RetentionMaxPosts = maxPosts
+	fmt.Printf("retention for %s: %s\n", feed.Name, describeRetention(feed))
+
+	return nil
+}
+
+// describeRetention is how feeds reports a feed's retention policy.
+func describeRetention(feed database.Feed) string {
+	age := "default max age"
+	if feed.RetentionMaxAgeSeconds.Valid {
+		if feed.RetentionMaxAgeSeconds.Int32 == 0 {
+			age = "no max age"
+		} else {
+			age = fmt.Sprintf("max age %s", time.Duration(feed.RetentionMaxAgeSeconds.Int32)*time.Second)
+		}
+	}
+
+	count := "default max posts"
+	if feed.RetentionMaxPosts.Valid {
+		if feed.RetentionMaxPosts.Int32 == 0 {
+			count = "no max posts"
+		} else {
+			count = fmt.Sprintf("max %d posts", feed.RetentionMaxPosts.Int32)
+		}
+	}
+
+	return fmt.Sprintf("%s, %s", age, count)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/rules"
+)
+
+func handlerRules(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("rules expects a sub-command: add, list or remove")
+	}
+	sub := command{name: cmd.args[0], args: cmd.args[1:]}
+
+	switch sub.name {
+	case "add":
+		return rulesAdd(s, sub, user)
+	case "list":
+		return rulesList(s, user)
+	case "remove":
+		return rulesRemove(s, sub, user)
+	default:
+		return fmt.Errorf("unknown rules sub-command: %s", sub.name)
+	}
+}
+
+// rulesAdd handles both
+//
+//	rules add <mute|highlight> <keyword|regex> <pattern> [feed_url]
+//	rules add <mute|highlight> feed <feed_url>
+func rulesAdd(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 3 || len(cmd.args) > 4 {
+		return fmt.Errorf("rules add expects: <mute|highlight> <keyword|regex> <pattern> [feed_url], or <mute|highlight> feed <feed_url>")
+	}
+	action, kind := cmd.args[0], cmd.args[1]
+
+	pattern, feedURL := "", ""
+	if kind == rules.Feed {
+		feedURL = cmd.args[2]
+	} else {
+		pattern = cmd.args[2]
+		if len(cmd.args) == 4 {
+			feedURL = cmd.args[3]
+		}
+	}
+
+	feedID := uuid.NullUUID{}
+	if feedURL != "" {
+		feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+		if err != nil {
+			return fmt.Errorf("unable to get feed by url: %w", err)
+		}
+		feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+
+	//compile up front so a bad regex is never stored
+	if _, err := rules.Compile(action, kind, pattern, feedID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	args := database.CreateRuleParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Action:    action,
+		Kind:      kind,
+		Pattern:   pattern,
+		FeedID:    feedID,
+	}
+	rule, err := s.db.CreateRule(context.Background(), args)
+	if err != nil {
+		return fmt.Errorf("unable to create rule: %w", err)
+	}
+
+	fmt.Printf("rule %s created\n", rule.ID)
+
+	return nil
+}
+
+func rulesList(s *state, user database.User) error {
+	userRules, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("unable to get rules for user: %w", err)
+	}
+
+	for _, rule := range userRules {
+		fmt.Printf("ID: %s\n", rule.ID)
+		fmt.Printf("Action: %s\n", rule.Action)
+		if rule.Kind != rules.Feed {
+			fmt.Printf("%s: %s\n", rule.Kind, rule.Pattern)
+		}
+		if rule.FeedUrl.Valid {
+			fmt.Printf("Feed: %s\n", rule.FeedUrl.String)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func rulesRemove(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("rules remove expects 1 argument: id")
+	}
+
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse rule id: %s [%w]", cmd.args[0], err)
+	}
+
+	args := database.DeleteRuleParams{
+		ID:     id,
+		UserID: user.ID,
+	}
+	if err := s.db.DeleteRule(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to delete rule: %w", err)
+	}
+
+	fmt.Printf("rule %s removed\n", id)
+
+	return nil
+}
+
+func loadRules(s *state, user database.User) ([]rules.Rule, error) {
+	stored, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get rules for user: %w", err)
+	}
+
+	var compiled []rules.Rule
+	for _, rule := range stored {
+		c, err := rules.Compile(rule.Action, rule.Kind, rule.Pattern, rule.FeedID)
+		if err != nil {
+			s.logger.Warn("skipping invalid rule", "rule_id", rule.ID, "error", err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
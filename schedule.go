@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/config"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const (
+	defaultMinPollInterval = 15 * time.Minute
+	defaultMaxPollInterval = 24 * time.Hour
+
+	// minRetryInterval keeps a feed that keeps failing from being retried
+	// on every tick of agg, whatever the poll bounds allow.
+	minRetryInterval = time.Minute
+
+	// cadenceSampleSize is how many of a feed's latest posts its publishing
+	// cadence is learned from.
+	cadenceSampleSize = 20
+)
+
+// pollBounds limit how often and how rarely any feed is fetched, whatever
+// its history or its own hints say.
+type pollBounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func pollIntervalBounds(cfg *config.Config) (pollBounds, error) {
+	bounds := pollBounds{Min: defaultMinPollInterval, Max: defaultMaxPollInterval}
+
+	if cfg.MinPollInterval != "" {
+		d, err := time.ParseDuration(cfg.MinPollInterval)
+		if err != nil {
+			return pollBounds{}, fmt.Errorf("unable to parse min_poll_interval: %s [%w]", cfg.MinPollInterval, err)
+		}
+		bounds.Min = d
+	}
+	if cfg.MaxPollInterval != "" {
+		d, err := time.ParseDuration(cfg.MaxPollInterval)
+		if err != nil {
+			return pollBounds{}, fmt.Errorf("unable to parse max_poll_interval: %s [%w]", cfg.MaxPollInterval, err)
+		}
+		bounds.Max = d
+	}
+	if bounds.Min <= 0 {
+		return pollBounds{}, fmt.Errorf("min_poll_interval must be positive: %s", bounds.Min)
+	}
+	if bounds.Max <= 0 {
+		return pollBounds{}, fmt.Errorf("max_poll_interval must be positive: %s", bounds.Max)
+	}
+	//Intervals are stored in whole seconds as an int32
+	if bounds.Max/time.Second > math.MaxInt32 {
+		return pollBounds{}, fmt.Errorf("max_poll_interval must be at most %s", time.Duration(math.MaxInt32)*time.Second)
+	}
+	if bounds.Min > bounds.Max {
+		return pollBounds{}, fmt.Errorf("min_poll_interval %s is longer than max_poll_interval %s", bounds.Min, bounds.Max)
+	}
+
+	return bounds, nil
+}
+
+func (b pollBounds) clamp(d time.Duration) time.Duration {
+	return min(max(d, b.Min), b.Max)
+}
+
+// adaptiveInterval polls roughly twice per expected post, going by the
+// average gap between recent posts, and backs off once a feed goes quiet.
+func adaptiveInterval(now time.Time, cadence database.GetPostCadenceForFeedRow, bounds pollBounds) time.Duration {
+	if cadence.PostCount < 2 {
+		//not enough history to tell, so treat it as dormant
+		return bounds.Max
+	}
+
+	gap := cadence.Newest.Sub(cadence.Oldest) / time.Duration(cadence.PostCount-1)
+	interval := gap / 2
+	if quiet := now.Sub(cadence.Newest); quiet > gap {
+		interval = max(interval, quiet/2)
+	}
+
+	return bounds.clamp(interval)
+}
+
+// scheduleFeed decides when a feed is next due and the interval that was
+// used, honouring a manual override before anything else.
+func scheduleFeed(ctx context.Context, s *state, feed database.Feed, stream *feedStream, now time.Time) (time.Time, time.Duration, error) {
+	if feed.PollIntervalOverrideSeconds.Valid {
+		interval := time.Duration(feed.PollIntervalOverrideSeconds.Int32) * time.Second
+		return now.Add(interval), interval, nil
+	}
+
+	bounds, err := pollIntervalBounds(s.cfg)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	//Pushed feeds are only polled as a safety net
+	activeArgs := database.HasActiveWebSubSubscriptionParams{
+		FeedID: feed.ID,
+		Now:    now,
+	}
+	pushed, err := s.db.HasActiveWebSubSubscription(ctx, activeArgs)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("unable to check websub subscription: %w", err)
+	}
+	if pushed {
+		next, interval := nextFetchAt(now, stream.Channel, stream.Cache, bounds.Max, bounds)
+		return next, interval, nil
+	}
+
+	args := database.GetPostCadenceForFeedParams{
+		FeedID:     feed.ID,
+		SampleSize: cadenceSampleSize,
+	}
+	cadence, err := s.db.GetPostCadenceForFeed(ctx, args)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("unable to get post cadence: %w", err)
+	}
+
+	next, interval := nextFetchAt(now, stream.Channel, stream.Cache, adaptiveInterval(now, cadence, bounds), bounds)
+	return next, interval, nil
+}
+
+// scheduleRetry puts off a feed that couldn't be fetched, doubling the
+// wait with each failure in a row, and returns when it is next due.
+func scheduleRetry(ctx context.Context, s *state, feed database.Feed, now time.Time) (time.Time, error) {
+	bounds, err := pollIntervalBounds(s.cfg)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	//An override is how often the user wants it tried, so back off from there
+	base := bounds.Min
+	if feed.PollIntervalOverrideSeconds.Valid {
+		base = time.Duration(feed.PollIntervalOverrideSeconds.Int32) * time.Second
+	}
+	next := now.Add(retryInterval(feed.FetchFailures, base, max(base, bounds.Max)))
+
+	args := database.SetFeedRetryParams{
+		ID:          feed.ID,
+		NextFetchAt: sql.NullTime{Time: next, Valid: true},
+		UpdatedAt:   now,
+	}
+	if err := s.db.SetFeedRetry(ctx, args); err != nil {
+		return time.Time{}, fmt.Errorf("unable to set feed retry: %w", err)
+	}
+	return next, nil
+}
+
+// retryInterval is base doubled once for every earlier failure, up to limit,
+// and never shorter than minRetryInterval.
+func retryInterval(failures int32, base, limit time.Duration) time.Duration {
+	interval := max(base, minRetryInterval)
+	limit = max(limit, minRetryInterval)
+	for i := int32(0); i < failures && interval < limit; i++ {
+		interval *= 2
+	}
+	return min(interval, limit)
+}
+
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// cacheHints are the freshness headers of a feed response.
+type cacheHints struct {
+	MaxAge  time.Duration
+	Expires time.Time
+}
+
+func parseCacheHints(header http.Header, now time.Time) cacheHints {
+	var hints cacheHints
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+				hints.MaxAge = time.Duration(seconds) * time.Second
+			}
+		case "no-cache", "no-store":
+			//no-cache wins over any max-age
+			return cacheHints{}
+		}
+	}
+
+	//Expires only counts when max-age is absent
+	if hints.MaxAge == 0 {
+		if expires, err := http.ParseTime(header.Get("Expires")); err == nil && expires.After(now) {
+			hints.Expires = expires
+		}
+	}
+
+	return hints
+}
+
+// nextFetchAt works out when a feed should next be fetched: no sooner than
+// its learned interval, ttl, syndication update period or cache headers
+// allow, no later than bounds.Max, and outside skipHours/skipDays. It also
+// returns the interval before skipping.
+func nextFetchAt(now time.Time, channel RSSChannel, cache cacheHints, interval time.Duration, bounds pollBounds) (time.Time, time.Duration) {
+	wait := max(interval, declaredInterval(channel), cache.MaxAge)
+	if !cache.Expires.IsZero() && cache.Expires.Sub(now) > wait {
+		wait = cache.Expires.Sub(now)
+	}
+	wait = bounds.clamp(wait)
+
+	return skipForward(now.Add(wait), channel), wait
+}
+
+// declaredInterval is the longer of the feed's ttl and its syndication
+// module update period.
+func declaredInterval(channel RSSChannel) time.Duration {
+	var wait time.Duration
+
+	if minutes, err := strconv.Atoi(strings.TrimSpace(channel.TTL)); err == nil && minutes > 0 {
+		wait = time.Duration(minutes) * time.Minute
+	}
+
+	if period, ok := updatePeriods[strings.ToLower(strings.TrimSpace(channel.UpdatePeriod))]; ok {
+		frequency := 1
+		if n, err := strconv.Atoi(strings.TrimSpace(channel.UpdateFrequency)); err == nil && n > 0 {
+			frequency = n
+		}
+		if interval := period / time.Duration(frequency); interval > wait {
+			wait = interval
+		}
+	}
+
+	return wait
+}
+
+// skipForward moves t to the start of the first hour the feed doesn't ask
+// to be skipped. skipHours and skipDays are in GMT.
+func skipForward(t time.Time, channel RSSChannel) time.Time {
+	if len(channel.SkipHours) == 0 && len(channel.SkipDays) == 0 {
+		return t
+	}
+
+	skipHours := map[int]bool{}
+	for _, hour := range channel.SkipHours {
+		skipHours[hour%24] = true
+	}
+	skipDays := map[time.Weekday]bool{}
+	for _, day := range channel.SkipDays {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(strings.TrimSpace(day), weekday.String()) {
+				skipDays[weekday] = true
+			}
+		}
+	}
+
+	//a week of hours covers every combination, so give up after that
+	for range 7 * 24 {
+		utc := t.UTC()
+		if !skipHours[utc.Hour()] && !skipDays[utc.Weekday()] {
+			return t
+		}
+		t = utc.Truncate(time.Hour).Add(time.Hour)
+	}
+	return t
+}
+
+func handlerPollInterval(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("pollinterval expects 2 arguments: feed_url duration|auto")
+	}
+	feedURL, value := cmd.args[0], cmd.args[1]
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("unable to get feed by url: %w", err)
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added %s can change its poll interval", feed.Url)
+	}
+
+	override := sql.NullInt32{}
+	if value != "auto" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("unable to parse duration: %s [%w]", value, err)
+		}
+		if d < time.Minute {
+			return fmt.Errorf("poll interval must be at least a minute")
+		}
+		//Stored as int32 seconds
+		if d/time.Second > math.MaxInt32 {
+			return fmt.Errorf("poll interval must be at most %s", time.Duration(math.MaxInt32)*time.Second)
+		}
+		override = sql.NullInt32{Int32: int32(d / time.Second), Valid: true}
+	}
+
+	args := database.SetFeedPollOverrideParams{
+		ID:                          feed.ID,
+		PollIntervalOverrideSeconds: override,
+		UpdatedAt:                   time.Now(),
+	}
+	if err := s.db.SetFeedPollOverride(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to set poll interval: %w", err)
+	}
+
+	if override.Valid {
+		fmt.Printf("%s will be fetched every %s\n", feed.Name, value)
+	} else {
+		fmt.Printf("%s will be fetched at its learned interval\n", feed.Name)
+	}
+
+	return nil
+}
+
+// describePollInterval is how feeds reports a feed's polling interval.
+func describePollInterval(feed database.Feed) string {
+	switch {
+	case feed.PollIntervalOverrideSeconds.Valid:
+		return fmt.Sprintf("%s (override)", time.Duration(feed.PollIntervalOverrideSeconds.Int32)*time.Second)
+	case feed.PollIntervalSeconds.Valid:
+		return fmt.Sprintf("%s (learned)", time.Duration(feed.PollIntervalSeconds.Int32)*time.Second)
+	default:
+		return "not fetched yet"
+	}
+}
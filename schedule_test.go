@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kbm-ky/gator/internal/config"
+)
+
+func TestRetryInterval(t *testing.T) {
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{0, 15 * time.Minute},
+		{1, 30 * time.Minute},
+		{3, 2 * time.Hour},
+		{6, 16 * time.Hour},
+		{7, 24 * time.Hour},
+		{1 << 30, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryInterval(tt.failures, 15*time.Minute, 24*time.Hour); got != tt.want {
+			t.Errorf("retryInterval(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+
+	//A zero base still waits, and doubles from the floor
+	if got := retryInterval(0, 0, time.Hour); got != minRetryInterval {
+		t.Errorf("retryInterval with no base = %s, want %s", got, minRetryInterval)
+	}
+	if got := retryInterval(2, 0, time.Hour); got != 4*minRetryInterval {
+		t.Errorf("retryInterval(2) with no base = %s, want %s", got, 4*minRetryInterval)
+	}
+	if got := retryInterval(5, 0, 0); got != minRetryInterval {
+		t.Errorf("retryInterval with no limit = %s, want %s", got, minRetryInterval)
+	}
+}
+
+func TestPollIntervalBounds(t *testing.T) {
+	tests := []struct {
+		min, max string
+		ok       bool
+	}{
+		{"", "", true},
+		{"5m", "2h", true},
+		{"1h", "1h", true},
+		{"2h", "1h", false},
+		{"0s", "1h", false},
+		{"-5m", "1h", false},
+		{"5m", "0s", false},
+		{"five", "1h", false},
+		{"5m", "596523h", true},
+		{"5m", "596524h", false},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{MinPollInterval: tt.min, MaxPollInterval: tt.max}
+		_, err := pollIntervalBounds(cfg)
+		if (err == nil) != tt.ok {
+			t.Errorf("pollIntervalBounds(%q, %q) error = %v, want ok %t", tt.min, tt.max, err, tt.ok)
+		}
+	}
+}
+
+func TestScrapeFeedRetries(t *testing.T) {
+	s := newTestState(t)
+	ctx := context.Background()
+
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("<rss><channel><title>Gators</title><ttl>60</ttl></channel></rss>"))
+	}))
+	t.Cleanup(server.Close)
+
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", server.URL)
+
+	for failures, wait := range []time.Duration{defaultMinPollInterval, 2 * defaultMinPollInterval, 4 * defaultMinPollInterval} {
+		feed, err := s.db.GetFeedByUrl(ctx, feed.Url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feed.FetchFailures != int32(failures) {
+			t.Fatalf("fetch_failures = %d, want %d", feed.FetchFailures, failures)
+		}
+
+		start := time.Now()
+		if err := scrapeFeed(s, feed); err != nil {
+			t.Fatal(err)
+		}
+
+		feed, err = s.db.GetFeedByUrl(ctx, feed.Url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !feed.NextFetchAt.Valid {
+			t.Fatalf("failure %d left the feed unscheduled", failures+1)
+		}
+		if got := feed.NextFetchAt.Time.Sub(start); got < wait || got > wait+time.Minute {
+			t.Errorf("failure %d: next fetch in %s, want %s", failures+1, got, wait)
+		}
+	}
+
+	//A good fetch goes back to the usual schedule
+	healthy.Store(true)
+	feed, err := s.db.GetFeedByUrl(ctx, feed.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scrapeFeed(s, feed); err != nil {
+		t.Fatal(err)
+	}
+	feed, err = s.db.GetFeedByUrl(ctx, feed.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.FetchFailures != 0 {
+		t.Errorf("fetch_failures = %d after a good fetch, want 0", feed.FetchFailures)
+	}
+}
+
+func TestHandlerPollInterval(t *testing.T) {
+	s := newTestState(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", "https://gators.example/feed")
+	fetched := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	_, err := s.conn.Exec("UPDATE feeds SET last_fetched_at = $1, next_fetch_at = $2 WHERE id = $3",
+		fetched, fetched.Add(24*time.Hour), feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pollInterval := func(value string) error {
+		return handlerPollInterval(s, command{name: "pollinterval", args: []string{feed.Url, value}}, user)
+	}
+
+	for _, value := range []string{"30s", "100000h", "soon"} {
+		if err := pollInterval(value); err == nil {
+			t.Errorf("pollinterval %s was accepted", value)
+		}
+	}
+
+	if err := pollInterval("1h"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.db.GetFeedByUrl(ctx, feed.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PollIntervalOverrideSeconds.Int32 != 3600 {
+		t.Errorf("override = %v, want an hour", got.PollIntervalOverrideSeconds)
+	}
+	if !got.NextFetchAt.Valid || !got.NextFetchAt.Time.Equal(fetched.Add(time.Hour)) {
+		t.Errorf("next fetch = %v, want an hour after the last fetch at %s", got.NextFetchAt, fetched)
+	}
+
+	if err := pollInterval("auto"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.db.GetFeedByUrl(ctx, feed.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PollIntervalOverrideSeconds.Valid || got.NextFetchAt.Valid {
+		t.Errorf("override %v, next fetch %v, want both cleared", got.PollIntervalOverrideSeconds, got.NextFetchAt)
+	}
+}
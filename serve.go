@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func handlerServe(s *state, cmd command) error {
+	//check args
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("serve expects 1 argument: listen address")
+	}
+	addr := cmd.args[0]
+
+	mux := http.NewServeMux()
+	registerPublishRoutes(s, mux)
+	registerFeverRoutes(s, mux)
+	registerGReaderRoutes(s, mux)
+	registerWebSubRoutes(s, mux)
+
+	//Hubs need a public address to call back, so only subscribe when there is one
+	if s.cfg.PublicURL != "" {
+		go maintainWebSub(context.Background(), s, s.cfg.PublicURL)
+	} else {
+		s.logger.Info("public_url is not configured, not subscribing to websub hubs")
+	}
+	//Hub pushes save posts here, so their webhooks shouldn't wait for agg
+	go runWebhookWorker(s, webhookClient)
+
+	fmt.Printf("Serving on %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func postTime(publishedAt sql.NullTime, createdAt time.Time) time.Time {
+	if publishedAt.Valid {
+		return publishedAt.Time
+	}
+	return createdAt
+}
+
+func writeJSON(w http.ResponseWriter, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,26 @@
+-- name: UpsertApiCredentials :one
+INSERT INTO api_credentials (id, created_at, updated_at, user_id, password_hash, fever_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (user_id) DO UPDATE
+SET password_hash = EXCLUDED.password_hash, fever_key = EXCLUDED.fever_key, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetUserByFeverKey :one
+SELECT users.*
+FROM users
+INNER JOIN api_credentials on api_credentials.user_id = users.id
+WHERE api_credentials.fever_key = $1
+LIMIT 1;
+
+-- name: GetApiCredentialsForUser :one
+SELECT *
+FROM api_credentials
+WHERE user_id = $1
+LIMIT 1;
\ No newline at end of file
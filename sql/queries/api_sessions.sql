@@ -0,0 +1,17 @@
+-- name: CreateApiSession :one
+INSERT INTO api_sessions (id, created_at, updated_at, user_id, token)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetUserByApiSession :one
+SELECT users.*
+FROM users
+INNER JOIN api_sessions on api_sessions.user_id = users.id
+WHERE api_sessions.token = $1
+LIMIT 1;
\ No newline at end of file
@@ -0,0 +1,40 @@
+-- name: UpsertDigest :one
+INSERT INTO digests (id, created_at, updated_at, user_id, email, frequency)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (user_id) DO UPDATE
+SET email = EXCLUDED.email, frequency = EXCLUDED.frequency, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: DeleteDigest :exec
+DELETE FROM digests
+WHERE user_id = $1;
+
+-- name: GetDigests :many
+SELECT
+digests.*,
+users.name as user_name
+FROM digests
+INNER JOIN users on users.id = digests.user_id
+ORDER BY users.name;
+
+-- name: MarkDigestSent :exec
+UPDATE digests
+SET last_sent_at = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: GetDigestPostsForUser :many
+SELECT
+posts.*,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id) AND posts.created_at > sqlc.arg(since) AND posts.created_at <= sqlc.arg(until)
+ORDER BY feed_name, posts.published_at DESC NULLS LAST;
\ No newline at end of file
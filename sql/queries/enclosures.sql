@@ -0,0 +1,36 @@
+-- name: CreateEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds, episode, season)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+ON CONFLICT (post_id, url) DO NOTHING;
+
+-- name: GetEnclosuresForPost :many
+SELECT *
+FROM enclosures
+WHERE post_id = $1
+ORDER BY created_at;
+
+-- name: GetEnclosuresForUser :many
+SELECT
+enclosures.*,
+posts.seq as post_seq,
+posts.title as post_title,
+feeds.id as feed_id,
+feeds.name as feed_name,
+(ROW_NUMBER() OVER (PARTITION BY enclosures.post_id ORDER BY enclosures.created_at, enclosures.url))::integer as position
+FROM enclosures
+INNER JOIN posts on posts.id = enclosures.post_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+INNER JOIN feed_follows on feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name, feeds.id, posts.published_at DESC NULLS LAST, posts.seq DESC, position;
\ No newline at end of file
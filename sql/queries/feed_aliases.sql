@@ -0,0 +1,20 @@
+-- name: CreateFeedAlias :exec
+INSERT INTO feed_aliases (id, created_at, updated_at, feed_id, url)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (url) DO UPDATE
+SET feed_id = EXCLUDED.feed_id, updated_at = EXCLUDED.updated_at;
+
+-- name: DeleteFeedAliasByUrl :exec
+DELETE FROM feed_aliases
+WHERE url = $1;
+
+-- name: MoveFeedAliases :exec
+UPDATE feed_aliases
+SET feed_id = sqlc.arg(to_feed_id), updated_at = sqlc.arg(updated_at)
+WHERE feed_id = sqlc.arg(from_feed_id);
\ No newline at end of file
@@ -21,7 +21,7 @@ INNER JOIN users on users.id = inserted_feed_follow.user_id;
 -- name: GetFeedFollowsForUserByName :many
 SELECT 
 feed_follows.*,
-feeds.name as feed_name,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
 users.name as user_name
 FROM feed_follows
 INNER JOIN feeds on feeds.id = feed_follows.feed_id
@@ -31,13 +31,51 @@ INNER JOIN users on users.id = feed_follows.user_id and users.name = $1;
 -- name: GetFeedFollowsForUser :many
 SELECT 
 feed_follows.*,
-feeds.name as feed_name,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
 users.name as user_name
 FROM feed_follows
 INNER JOIN feeds on feeds.id = feed_follows.feed_id
 INNER JOIN users on users.id = feed_follows.user_id
-WHERE feed_follows.user_id = $1;
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.priority DESC, feed_name;
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE user_id = $1 AND feed_id = $2;
+
+
+-- name: MergeFeedFollowPreferences :exec
+-- For users following both feeds, the follow of to_feed_id keeps its own
+-- title and priority when it has them and takes those of from_feed_id's
+-- follow otherwise
+UPDATE feed_follows
+SET title = COALESCE(feed_follows.title, old.title),
+    priority = CASE WHEN feed_follows.priority = 0 THEN old.priority ELSE feed_follows.priority END,
+    updated_at = sqlc.arg(updated_at)
+FROM feed_follows old
+WHERE feed_follows.feed_id = sqlc.arg(to_feed_id)
+AND old.feed_id = sqlc.arg(from_feed_id)
+AND old.user_id = feed_follows.user_id;
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(to_feed_id), updated_at = sqlc.arg(updated_at)
+WHERE feed_follows.feed_id = sqlc.arg(from_feed_id)
+AND feed_follows.user_id NOT IN (
+    SELECT existing.user_id FROM feed_follows existing WHERE existing.feed_id = sqlc.arg(to_feed_id)
+);
+
+-- name: GetFeedFollowForUser :one
+SELECT *
+FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: SetFeedFollowTitle :exec
+UPDATE feed_follows
+SET title = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: SetFeedFollowPriority :exec
+UPDATE feed_follows
+SET priority = $3, updated_at = $4
+WHERE user_id = $1 AND feed_id = $2;
\ No newline at end of file
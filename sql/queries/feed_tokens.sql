@@ -0,0 +1,19 @@
+-- name: UpsertFeedToken :one
+INSERT INTO feed_tokens (id, created_at, updated_at, user_id, token)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id) DO UPDATE
+SET token = EXCLUDED.token, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetUserByFeedToken :one
+SELECT users.*
+FROM users
+INNER JOIN feed_tokens on feed_tokens.user_id = users.id
+WHERE feed_tokens.token = $1
+LIMIT 1;
\ No newline at end of file
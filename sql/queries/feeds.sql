@@ -17,7 +17,9 @@ SELECT * FROM feeds;
 SELECT 
 *
 FROM feeds
-WHERE url = $1
+WHERE feeds.url = $1
+OR feeds.id IN (SELECT feed_aliases.feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+ORDER BY feeds.url = $1 DESC
 LIMIT 1;
 
 -- name: MarkFeedFetched :exec
@@ -25,8 +27,112 @@ UPDATE feeds
 SET last_fetched_at = $2, updated_at = $3
 WHERE id = $1;
 
+-- name: MarkFeedSucceeded :exec
+UPDATE feeds
+SET last_succeeded_at = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: GetStalestFeedSuccess :one
+SELECT COALESCE(last_succeeded_at, created_at)::timestamp AS last_succeeded_at
+FROM feeds
+ORDER BY 1
+LIMIT 1;
+
 -- name: GetNextFeedToFetch :one
 SELECT *
 FROM feeds
+WHERE (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now)::timestamp)
+AND (sqlc.narg(tag_user_id)::uuid IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follows
+    INNER JOIN feed_follow_tags on feed_follow_tags.feed_follow_id = feed_follows.id
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = sqlc.narg(tag_user_id)::uuid
+    AND tags.name = sqlc.narg(tag)::text
+))
 ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: SetFeedSchedule :exec
+UPDATE feeds
+SET next_fetch_at = $2, poll_interval_seconds = $3, fetch_failures = 0, updated_at = $4
+WHERE id = $1;
+
+-- name: SetFeedRetry :exec
+UPDATE feeds
+SET next_fetch_at = $2, fetch_failures = fetch_failures + 1, updated_at = $3
+WHERE id = $1;
+
+-- name: SetFeedPollOverride :exec
+-- A new override counts from the last fetch, and clearing one makes the feed
+-- due so its interval is learned again
+UPDATE feeds
+SET poll_interval_override_seconds = sqlc.narg(poll_interval_override_seconds)::integer,
+    next_fetch_at = CASE
+        WHEN sqlc.narg(poll_interval_override_seconds)::integer IS NULL THEN NULL
+        WHEN last_fetched_at IS NULL THEN next_fetch_at
+        ELSE last_fetched_at + sqlc.narg(poll_interval_override_seconds)::integer * interval '1 second'
+    END,
+    updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id);
+
+-- name: GetFeedForUserBySeq :one
+SELECT feeds.*
+FROM feeds
+INNER JOIN feed_follows on feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND feeds.seq = $2;
+
+-- name: GetFeedsForUser :many
+SELECT feeds.*
+FROM feeds
+INNER JOIN feed_follows on feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.seq;
+
+-- name: UpdateFeedUrl :exec
+UPDATE feeds
+SET url = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: SetFeedHub :exec
+UPDATE feeds
+SET hub_url = $2, self_url = $3, updated_at = $4
+WHERE id = $1;
+
+-- name: GetFeed :one
+SELECT *
+FROM feeds
+WHERE id = $1;
+
+-- name: SetFeedFullArticle :exec
+UPDATE feeds
+SET fetch_full_article = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: GetFeedListForUser :many
+SELECT
+feeds.*,
+COALESCE(feed_follows.title, feeds.name)::text as title,
+feed_follows.priority,
+COUNT(posts.id) FILTER (WHERE NOT COALESCE(post_states.read, FALSE))::bigint as unread
+FROM feed_follows
+INNER JOIN feeds on feeds.id = feed_follows.feed_id
+LEFT JOIN posts on posts.feed_id = feeds.id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+GROUP BY feeds.id, feed_follows.id
+ORDER BY feed_follows.priority DESC, COALESCE(feed_follows.title, feeds.name);
+
+-- name: SetFeedItemsSeen :exec
+UPDATE feeds
+SET items_seen_at = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_age_seconds = $2, retention_max_posts = $3, updated_at = $4
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- name: CreatePostCategory :exec
+INSERT INTO post_categories (id, created_at, updated_at, post_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (post_id, name) DO NOTHING;
+
+-- name: GetCategoriesForPost :many
+SELECT name
+FROM post_categories
+WHERE post_id = $1
+ORDER BY name;
\ No newline at end of file
@@ -0,0 +1,55 @@
+-- name: SetPostRead :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, read)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = EXCLUDED.read, updated_at = EXCLUDED.updated_at;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, starred)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred = EXCLUDED.starred, updated_at = EXCLUDED.updated_at;
+
+-- name: MarkFeedReadForUser :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, read)
+SELECT gen_random_uuid(), sqlc.arg(now)::timestamp, sqlc.arg(now)::timestamp, feed_follows.user_id, posts.id, TRUE
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id) AND posts.feed_id = sqlc.arg(feed_id)
+AND COALESCE(posts.published_at, posts.created_at) < sqlc.arg(before)::timestamp
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = TRUE, updated_at = EXCLUDED.updated_at;
+
+-- name: MarkAllReadForUser :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, read)
+SELECT gen_random_uuid(), sqlc.arg(now)::timestamp, sqlc.arg(now)::timestamp, feed_follows.user_id, posts.id, TRUE
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id) AND COALESCE(posts.published_at, posts.created_at) < sqlc.arg(before)::timestamp
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = TRUE, updated_at = EXCLUDED.updated_at;
+
+-- name: MarkTagReadForUser :exec
+INSERT INTO post_states (id, created_at, updated_at, user_id, post_id, read)
+SELECT gen_random_uuid(), sqlc.arg(now)::timestamp, sqlc.arg(now)::timestamp, feed_follows.user_id, posts.id, TRUE
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feed_follow_tags on feed_follow_tags.feed_follow_id = feed_follows.id
+INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+WHERE feed_follows.user_id = sqlc.arg(user_id) AND tags.name = sqlc.arg(tag) AND COALESCE(posts.published_at, posts.created_at) < sqlc.arg(before)::timestamp
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = TRUE, updated_at = EXCLUDED.updated_at;
\ No newline at end of file
@@ -1,5 +1,5 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content, author, comments_url, last_seen_at)
 VALUES (
     $1,
     $2,
@@ -8,7 +8,11 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11,
+    $2
 )
 RETURNING *;
 
@@ -17,5 +21,295 @@ SELECT posts.*
 FROM posts
 INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
 WHERE feed_follows.user_id = $1
+AND (sqlc.narg(author)::text IS NULL OR posts.author ILIKE '%' || sqlc.narg(author)::text || '%')
+AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    WHERE post_categories.post_id = posts.id AND LOWER(post_categories.name) = LOWER(sqlc.narg(category)::text)
+))
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = sqlc.narg(tag)::text
+))
+-- Each point of priority counts as a day of recency, see handlerPriority
+ORDER BY posts.published_at + feed_follows.priority * interval '1 day' DESC NULLS LAST, posts.seq DESC
+LIMIT $2 OFFSET $3;
+
+-- name: SearchPostsForUser :many
+SELECT posts.*
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+AND (
+    posts.title ILIKE '%' || sqlc.arg(query)::text || '%'
+    OR posts.description ILIKE '%' || sqlc.arg(query)::text || '%'
+    OR posts.content ILIKE '%' || sqlc.arg(query)::text || '%'
+)
+AND (sqlc.narg(author)::text IS NULL OR posts.author ILIKE '%' || sqlc.narg(author)::text || '%')
+AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    WHERE post_categories.post_id = posts.id AND LOWER(post_categories.name) = LOWER(sqlc.narg(category)::text)
+))
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = sqlc.narg(tag)::text
+))
+-- Ordered the same way as GetPostsForUser
+ORDER BY posts.published_at + feed_follows.priority * interval '1 day' DESC NULLS LAST, posts.seq DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetTimelineForUser :many
+SELECT
+posts.*,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+WHERE feed_follows.user_id = $1
 ORDER BY posts.published_at DESC NULLS LAST
-LIMIT $2;
\ No newline at end of file
+LIMIT $2;
+
+-- name: GetItemsForUserSince :many
+SELECT
+posts.*,
+feeds.seq as feed_seq,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq > $2
+ORDER BY posts.seq ASC
+LIMIT $3;
+
+-- name: GetItemsForUserBefore :many
+SELECT
+posts.*,
+feeds.seq as feed_seq,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq < $2
+ORDER BY posts.seq DESC
+LIMIT $3;
+
+-- name: GetItemsForUserBySeqs :many
+SELECT
+posts.*,
+feeds.seq as feed_seq,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq = ANY(sqlc.arg(seqs)::bigint[])
+ORDER BY posts.seq ASC;
+
+-- name: CountPostsForUser :one
+SELECT COUNT(*)
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1;
+
+-- name: GetUnreadPostSeqsForUser :many
+SELECT posts.seq
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND NOT COALESCE(post_states.read, FALSE)
+ORDER BY posts.seq;
+
+-- name: GetStarredPostSeqsForUser :many
+SELECT posts.seq
+FROM posts
+INNER JOIN post_states on post_states.post_id = posts.id
+WHERE post_states.user_id = $1 AND post_states.starred
+ORDER BY posts.seq;
+
+-- name: GetStreamItemsForUser :many
+SELECT
+posts.*,
+feeds.seq as feed_seq,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id)::uuid)
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = sqlc.narg(tag)::text
+))
+AND (NOT sqlc.arg(starred_only)::boolean OR COALESCE(post_states.starred, FALSE))
+AND (NOT sqlc.arg(unread_only)::boolean OR NOT COALESCE(post_states.read, FALSE))
+AND (sqlc.narg(older_than)::timestamp IS NULL OR posts.created_at < sqlc.narg(older_than)::timestamp)
+AND (sqlc.narg(newer_than)::timestamp IS NULL OR posts.created_at > sqlc.narg(newer_than)::timestamp)
+AND posts.seq < sqlc.arg(before_seq)
+ORDER BY posts.seq DESC
+LIMIT sqlc.arg(max_items);
+
+-- name: GetStreamItemsForUserOldestFirst :many
+SELECT
+posts.*,
+feeds.seq as feed_seq,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id)::uuid)
+AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+    SELECT 1 FROM feed_follow_tags
+    INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id AND tags.name = sqlc.narg(tag)::text
+))
+AND (NOT sqlc.arg(starred_only)::boolean OR COALESCE(post_states.starred, FALSE))
+AND (NOT sqlc.arg(unread_only)::boolean OR NOT COALESCE(post_states.read, FALSE))
+AND (sqlc.narg(older_than)::timestamp IS NULL OR posts.created_at < sqlc.narg(older_than)::timestamp)
+AND (sqlc.narg(newer_than)::timestamp IS NULL OR posts.created_at > sqlc.narg(newer_than)::timestamp)
+AND posts.seq > sqlc.arg(after_seq)
+ORDER BY posts.seq ASC
+LIMIT sqlc.arg(max_items);
+
+-- name: GetStreamItemsForUserBySeqs :many
+SELECT
+posts.*,
+feeds.seq as feed_seq,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+feeds.url as feed_url,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND posts.seq = ANY(sqlc.arg(seqs)::bigint[])
+ORDER BY posts.seq DESC;
+
+-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(to_feed_id), updated_at = sqlc.arg(updated_at)
+WHERE feed_id = sqlc.arg(from_feed_id);
+
+-- name: GetPostCadenceForFeed :one
+SELECT
+COUNT(*) AS post_count,
+COALESCE(MIN(recent.published_at), 'epoch')::timestamp AS oldest,
+COALESCE(MAX(recent.published_at), 'epoch')::timestamp AS newest
+FROM (
+    SELECT posts.published_at
+    FROM posts
+    WHERE posts.feed_id = $1 AND posts.published_at IS NOT NULL
+    ORDER BY posts.published_at DESC
+    LIMIT sqlc.arg(sample_size)
+) AS recent;
+
+-- name: SetPostArticle :exec
+UPDATE posts
+SET article = $2, article_fetched_at = sqlc.arg(fetched_at)::timestamp, updated_at = sqlc.arg(fetched_at)::timestamp
+WHERE id = $1;
+
+-- name: QueuePostArticle :exec
+UPDATE posts
+SET article_queued_at = sqlc.arg(queued_at)::timestamp, updated_at = sqlc.arg(queued_at)::timestamp
+WHERE id = $1;
+
+-- name: GetQueuedArticles :many
+SELECT *
+FROM posts
+WHERE article_queued_at IS NOT NULL AND article_fetched_at IS NULL
+ORDER BY article_queued_at
+LIMIT $1;
+
+-- name: GetPostForUserBySeq :one
+SELECT posts.*
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND posts.seq = $2;
+
+-- name: GetPostForUserByUrl :one
+SELECT posts.*
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1 AND posts.url = $2;
+
+-- name: GetPostListForUser :many
+SELECT
+posts.*,
+COALESCE(feed_follows.title, feeds.name)::text as feed_name,
+COALESCE(post_states.read, FALSE)::boolean as read,
+COALESCE(post_states.starred, FALSE)::boolean as starred
+FROM posts
+INNER JOIN feed_follows on posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+LEFT JOIN post_states on post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+AND (sqlc.narg(feed_id)::uuid IS NULL OR posts.feed_id = sqlc.narg(feed_id)::uuid)
+ORDER BY posts.published_at DESC NULLS LAST
+LIMIT $2;
+
+-- name: MarkPostSeen :exec
+UPDATE posts
+SET last_seen_at = sqlc.arg(seen_at)::timestamp
+WHERE url = $1;
+
+-- name: GetPrunablePosts :many
+WITH ranked AS (
+    SELECT
+    posts.id,
+    posts.feed_id,
+    posts.last_seen_at,
+    COALESCE(posts.published_at, posts.created_at) AS posted_at,
+    ROW_NUMBER() OVER (
+        PARTITION BY posts.feed_id
+        ORDER BY posts.published_at DESC NULLS LAST, posts.seq DESC
+    ) AS position
+    FROM posts
+)
+SELECT
+ranked.id,
+ranked.feed_id,
+feeds.name as feed_name
+FROM ranked
+INNER JOIN feeds on feeds.id = ranked.feed_id
+WHERE feeds.items_seen_at IS NOT NULL
+AND (ranked.last_seen_at IS NULL OR ranked.last_seen_at < feeds.items_seen_at)
+AND NOT EXISTS (
+    SELECT 1 FROM post_states
+    WHERE post_states.post_id = ranked.id AND post_states.starred
+)
+AND (
+    (
+        COALESCE(feeds.retention_max_age_seconds, sqlc.arg(max_age_seconds)::integer) > 0
+        AND ranked.posted_at < sqlc.arg(now)::timestamp - make_interval(secs => COALESCE(feeds.retention_max_age_seconds, sqlc.arg(max_age_seconds)::integer))
+    )
+    OR (
+        COALESCE(feeds.retention_max_posts, sqlc.arg(max_posts)::integer) > 0
+        AND ranked.position > COALESCE(feeds.retention_max_posts, sqlc.arg(max_posts)::integer)
+    )
+)
+ORDER BY feeds.name, ranked.feed_id;
+
+-- name: DeleteUnstarredPosts :execrows
+DELETE FROM posts
+WHERE posts.id = ANY(sqlc.arg(ids)::uuid[])
+AND NOT EXISTS (
+    SELECT 1 FROM post_states
+    WHERE post_states.post_id = posts.id AND post_states.starred
+);
\ No newline at end of file
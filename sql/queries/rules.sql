@@ -0,0 +1,31 @@
+-- name: CreateRule :one
+INSERT INTO rules (id, created_at, updated_at, user_id, action, kind, pattern, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetRulesForUser :many
+SELECT
+rules.*,
+feeds.url as feed_url
+FROM rules
+LEFT JOIN feeds on feeds.id = rules.feed_id
+WHERE rules.user_id = $1
+ORDER BY rules.created_at;
+
+-- name: DeleteRule :exec
+DELETE FROM rules
+WHERE id = $1 AND user_id = $2;
+
+-- name: MoveRules :exec
+UPDATE rules
+SET feed_id = sqlc.arg(to_feed_id)::uuid, updated_at = sqlc.arg(updated_at)
+WHERE feed_id = sqlc.arg(from_feed_id)::uuid;
\ No newline at end of file
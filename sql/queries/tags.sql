@@ -0,0 +1,66 @@
+-- name: UpsertTag :one
+INSERT INTO tags (id, created_at, updated_at, user_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetTagsForUser :many
+SELECT *
+FROM tags
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: AddFeedFollowTag :exec
+INSERT INTO feed_follow_tags (id, created_at, updated_at, feed_follow_id, tag_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (feed_follow_id, tag_id) DO NOTHING;
+
+-- name: MergeFeedFollowTags :exec
+-- For users following both feeds, copies the tags of from_feed_id's follow
+-- onto to_feed_id's
+INSERT INTO feed_follow_tags (id, created_at, updated_at, feed_follow_id, tag_id)
+SELECT gen_random_uuid(), sqlc.arg(now)::timestamp, sqlc.arg(now)::timestamp, survivor.id, feed_follow_tags.tag_id
+FROM feed_follow_tags
+INNER JOIN feed_follows old on old.id = feed_follow_tags.feed_follow_id
+INNER JOIN feed_follows survivor on survivor.user_id = old.user_id
+WHERE old.feed_id = sqlc.arg(from_feed_id)::uuid
+AND survivor.feed_id = sqlc.arg(to_feed_id)::uuid
+ON CONFLICT (feed_follow_id, tag_id) DO NOTHING;
+
+-- name: RemoveFeedFollowTag :exec
+DELETE FROM feed_follow_tags
+USING tags
+WHERE feed_follow_tags.tag_id = tags.id
+AND feed_follow_tags.feed_follow_id = sqlc.arg(feed_follow_id)
+AND tags.name = sqlc.arg(name);
+
+-- name: DeleteUnusedTagsForUser :exec
+DELETE FROM tags
+WHERE tags.user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM feed_follow_tags WHERE feed_follow_tags.tag_id = tags.id
+);
+
+-- name: GetFeedTagsForUser :many
+SELECT
+feed_follows.feed_id,
+tags.seq as tag_seq,
+tags.name as tag_name
+FROM feed_follow_tags
+INNER JOIN tags on tags.id = feed_follow_tags.tag_id
+INNER JOIN feed_follows on feed_follows.id = feed_follow_tags.feed_follow_id
+WHERE feed_follows.user_id = $1
+ORDER BY tags.name, feed_follows.feed_id;
\ No newline at end of file
@@ -0,0 +1,86 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, feed_id, secret)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT
+webhooks.*,
+feeds.url as feed_url
+FROM webhooks
+LEFT JOIN feeds on feeds.id = webhooks.feed_id
+WHERE webhooks.user_id = $1
+ORDER BY webhooks.created_at;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :exec
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, status, next_attempt_at)
+SELECT gen_random_uuid(), sqlc.arg(now)::timestamp, sqlc.arg(now)::timestamp, webhooks.id, sqlc.arg(post_id)::uuid, 'pending', sqlc.arg(now)::timestamp
+FROM webhooks
+INNER JOIN feed_follows on feed_follows.user_id = webhooks.user_id
+WHERE feed_follows.feed_id = sqlc.arg(feed_id)
+AND (webhooks.feed_id IS NULL OR webhooks.feed_id = feed_follows.feed_id)
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+
+-- name: ClaimDueWebhookDeliveries :many
+WITH claimed AS (
+    UPDATE webhook_deliveries
+    SET next_attempt_at = sqlc.arg(claim_until)::timestamp
+    WHERE webhook_deliveries.id IN (
+        SELECT id FROM webhook_deliveries
+        WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)::timestamp
+        ORDER BY next_attempt_at
+        LIMIT sqlc.arg(batch_size)
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING webhook_deliveries.*
+)
+SELECT
+claimed.*,
+webhooks.url as webhook_url,
+webhooks.secret as webhook_secret,
+posts.title as post_title,
+posts.url as post_url,
+posts.description as post_description,
+posts.published_at as post_published_at,
+feeds.id as feed_id,
+feeds.name as feed_name,
+feeds.url as feed_url
+FROM claimed
+INNER JOIN webhooks on webhooks.id = claimed.webhook_id
+INNER JOIN posts on posts.id = claimed.post_id
+INNER JOIN feeds on feeds.id = posts.feed_id
+ORDER BY claimed.created_at;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET updated_at = $2, status = $3, attempts = $4, next_attempt_at = $5, response_status = $6, last_error = $7, delivered_at = $8
+WHERE id = $1;
+
+-- name: GetWebhookDeliveriesForUser :many
+SELECT
+webhook_deliveries.*,
+webhooks.url as webhook_url,
+posts.url as post_url
+FROM webhook_deliveries
+INNER JOIN webhooks on webhooks.id = webhook_deliveries.webhook_id
+INNER JOIN posts on posts.id = webhook_deliveries.post_id
+WHERE webhooks.user_id = $1
+ORDER BY webhook_deliveries.updated_at DESC
+LIMIT $2;
+
+-- name: MoveWebhooks :exec
+UPDATE webhooks
+SET feed_id = sqlc.arg(to_feed_id)::uuid, updated_at = sqlc.arg(updated_at)
+WHERE feed_id = sqlc.arg(from_feed_id)::uuid;
\ No newline at end of file
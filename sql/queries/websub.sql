@@ -0,0 +1,57 @@
+-- name: UpsertWebSubSubscription :one
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret, status)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    'pending'
+)
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    status = CASE WHEN websub_subscriptions.status = 'active' THEN 'active' ELSE 'pending' END,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetWebSubSubscription :one
+SELECT *
+FROM websub_subscriptions
+WHERE id = $1;
+
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET status = 'active', lease_expires_at = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: SetWebSubSubscriptionStatus :exec
+UPDATE websub_subscriptions
+SET status = $2, updated_at = $3
+WHERE id = $1;
+
+-- name: GetFeedsNeedingWebSub :many
+SELECT
+feeds.id AS feed_id,
+feeds.hub_url::text AS hub_url,
+COALESCE(feeds.self_url, feeds.url)::text AS topic_url
+FROM feeds
+LEFT JOIN websub_subscriptions ON websub_subscriptions.feed_id = feeds.id
+WHERE feeds.hub_url IS NOT NULL
+AND (
+    websub_subscriptions.id IS NULL
+    OR websub_subscriptions.hub_url <> feeds.hub_url
+    OR (
+        websub_subscriptions.updated_at < sqlc.arg(retry_before)::timestamp
+        AND (websub_subscriptions.status <> 'active' OR websub_subscriptions.lease_expires_at < sqlc.arg(renew_before)::timestamp)
+    )
+);
+
+-- name: HasActiveWebSubSubscription :one
+SELECT EXISTS (
+    SELECT 1
+    FROM websub_subscriptions
+    WHERE feed_id = $1 AND status = 'active' AND lease_expires_at > sqlc.arg(now)::timestamp
+);
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE feed_tokens (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID UNIQUE NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token TEXT UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE feed_tokens;
\ No newline at end of file
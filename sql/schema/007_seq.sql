@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD seq BIGSERIAL NOT NULL UNIQUE;
+
+ALTER TABLE posts
+ADD seq BIGSERIAL NOT NULL UNIQUE;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN seq;
+
+ALTER TABLE feeds
+DROP COLUMN seq;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE post_states (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    starred BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE(user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_states;
\ No newline at end of file
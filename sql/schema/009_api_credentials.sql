@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE api_credentials (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID UNIQUE NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    fever_key TEXT UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE api_credentials;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE api_sessions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token TEXT UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE api_sessions;
\ No newline at end of file
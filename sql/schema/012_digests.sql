@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE digests (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID UNIQUE NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    frequency TEXT NOT NULL,
+    last_sent_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE digests;
\ No newline at end of file
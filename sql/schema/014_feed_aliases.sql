@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE feed_aliases (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    url TEXT UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE feed_aliases;
\ No newline at end of file
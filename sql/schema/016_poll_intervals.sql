@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD poll_interval_seconds INTEGER;
+
+ALTER TABLE feeds
+ADD poll_interval_override_seconds INTEGER;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN poll_interval_override_seconds;
+
+ALTER TABLE feeds
+DROP COLUMN poll_interval_seconds;
\ No newline at end of file
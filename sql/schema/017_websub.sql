@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD hub_url TEXT;
+
+ALTER TABLE feeds
+ADD self_url TEXT;
+
+CREATE TABLE websub_subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id UUID UNIQUE NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    status TEXT NOT NULL,
+    lease_expires_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
+
+ALTER TABLE feeds
+DROP COLUMN self_url;
+
+ALTER TABLE feeds
+DROP COLUMN hub_url;
\ No newline at end of file
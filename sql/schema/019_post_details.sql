@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE posts
+ADD content TEXT;
+
+ALTER TABLE posts
+ADD author TEXT;
+
+ALTER TABLE posts
+ADD comments_url TEXT;
+
+CREATE TABLE post_categories (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE(post_id, name)
+);
+
+-- +goose Down
+DROP TABLE post_categories;
+
+ALTER TABLE posts
+DROP COLUMN comments_url;
+
+ALTER TABLE posts
+DROP COLUMN author;
+
+ALTER TABLE posts
+DROP COLUMN content;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD fetch_full_article BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE posts
+ADD article TEXT;
+
+ALTER TABLE posts
+ADD article_fetched_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN article_fetched_at;
+
+ALTER TABLE posts
+DROP COLUMN article;
+
+ALTER TABLE feeds
+DROP COLUMN fetch_full_article;
\ No newline at end of file
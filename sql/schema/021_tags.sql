@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE tags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    seq BIGSERIAL NOT NULL UNIQUE,
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE feed_follow_tags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_follow_id UUID NOT NULL REFERENCES feed_follows (id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    UNIQUE(feed_follow_id, tag_id)
+);
+
+-- +goose Down
+DROP TABLE feed_follow_tags;
+
+DROP TABLE tags;
\ No newline at end of file
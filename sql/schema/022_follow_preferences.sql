@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feed_follows
+ADD title TEXT;
+
+ALTER TABLE feed_follows
+ADD priority INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN priority;
+
+ALTER TABLE feed_follows
+DROP COLUMN title;
\ No newline at end of file
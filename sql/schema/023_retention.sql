@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD retention_max_age_seconds INTEGER;
+
+ALTER TABLE feeds
+ADD retention_max_posts INTEGER;
+
+ALTER TABLE feeds
+ADD items_seen_at TIMESTAMP;
+
+ALTER TABLE posts
+ADD last_seen_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN last_seen_at;
+
+ALTER TABLE feeds
+DROP COLUMN items_seen_at;
+
+ALTER TABLE feeds
+DROP COLUMN retention_max_posts;
+
+ALTER TABLE feeds
+DROP COLUMN retention_max_age_seconds;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE posts
+ADD article_queued_at TIMESTAMP;
+
+CREATE INDEX posts_article_queue_idx ON posts (article_queued_at)
+WHERE article_queued_at IS NOT NULL AND article_fetched_at IS NULL;
+
+-- +goose Down
+DROP INDEX posts_article_queue_idx;
+
+ALTER TABLE posts
+DROP COLUMN article_queued_at;
\ No newline at end of file
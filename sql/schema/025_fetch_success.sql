@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD last_succeeded_at TIMESTAMP;
+
+UPDATE feeds
+SET last_succeeded_at = last_fetched_at;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN last_succeeded_at;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD fetch_failures INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN fetch_failures;
\ No newline at end of file
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+// normalizeTag makes "Tech " and "tech" the same tag.
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// feedTags maps each of a user's followed feeds to its tag names.
+func feedTags(ctx context.Context, s *state, user database.User) (map[uuid.UUID][]string, error) {
+	rows, err := s.db.GetFeedTagsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get feed tags for user: %w", err)
+	}
+
+	tags := map[uuid.UUID][]string{}
+	for _, row := range rows {
+		tags[row.FeedID] = append(tags[row.FeedID], row.TagName)
+	}
+	return tags, nil
+}
+
+// followedFeed is the user's follow of the feed at url.
+func followedFeed(ctx context.Context, s *state, user database.User, url string) (database.Feed, database.FeedFollow, error) {
+	feed, err := s.db.GetFeedByUrl(ctx, url)
+	if err != nil {
+		return database.Feed{}, database.FeedFollow{}, fmt.Errorf("unable to get feed by url: %w", err)
+	}
+
+	args := database.GetFeedFollowForUserParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	}
+	follow, err := s.db.GetFeedFollowForUser(ctx, args)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, database.FeedFollow{}, fmt.Errorf("you don't follow %s", feed.Url)
+	}
+	if err != nil {
+		return database.Feed{}, database.FeedFollow{}, fmt.Errorf("unable to get feed follow: %w", err)
+	}
+	return feed, follow, nil
+}
+
+func handlerTag(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 2 {
+		return fmt.Errorf("tag expects at least 2 arguments: feed_url tag [tag...]")
+	}
+	ctx := context.Background()
+
+	feed, follow, err := followedFeed(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	err = inTx(ctx, s, func(q *database.Queries) error {
+		for _, name := range cmd.args[1:] {
+			name = normalizeTag(name)
+			if name == "" {
+				continue
+			}
+
+			now := time.Now()
+			tagArgs := database.UpsertTagParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				UserID:    user.ID,
+				Name:      name,
+			}
+			tag, err := q.UpsertTag(ctx, tagArgs)
+			if err != nil {
+				return fmt.Errorf("unable to create tag: %w", err)
+			}
+
+			args := database.AddFeedFollowTagParams{
+				ID:           uuid.New(),
+				CreatedAt:    now,
+				UpdatedAt:    now,
+				FeedFollowID: follow.ID,
+				TagID:        tag.ID,
+			}
+			if err := q.AddFeedFollowTag(ctx, args); err != nil {
+				return fmt.Errorf("unable to tag feed: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return printFeedTags(ctx, s, user, feed)
+}
+
+func handlerUntag(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 2 {
+		return fmt.Errorf("untag expects at least 2 arguments: feed_url tag [tag...]")
+	}
+	ctx := context.Background()
+
+	feed, follow, err := followedFeed(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	err = inTx(ctx, s, func(q *database.Queries) error {
+		for _, name := range cmd.args[1:] {
+			args := database.RemoveFeedFollowTagParams{
+				FeedFollowID: follow.ID,
+				Name:         normalizeTag(name),
+			}
+			if err := q.RemoveFeedFollowTag(ctx, args); err != nil {
+				return fmt.Errorf("unable to untag feed: %w", err)
+			}
+		}
+
+		//A tag goes away with the last feed it was on
+		if err := q.DeleteUnusedTagsForUser(ctx, user.ID); err != nil {
+			return fmt.Errorf("unable to delete unused tags: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return printFeedTags(ctx, s, user, feed)
+}
+
+func printFeedTags(ctx context.Context, s *state, user database.User, feed database.Feed) error {
+	tags, err := feedTags(ctx, s, user)
+	if err != nil {
+		return err
+	}
+
+	if len(tags[feed.ID]) == 0 {
+		fmt.Printf("%s has no tags\n", feed.Name)
+	} else {
+		fmt.Printf("%s: %s\n", feed.Name, strings.Join(tags[feed.ID], ", "))
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/kbm-ky/gator/internal/termtext"
+)
+
+// terminalWidth is what post bodies are wrapped to: the terminal's width,
+// else $COLUMNS, else termtext.DefaultWidth.
+func terminalWidth() int {
+	if cols := terminalColumns(); cols > 0 {
+		return cols
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	return termtext.DefaultWidth
+}
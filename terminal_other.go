@@ -0,0 +1,24 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("terminal control is not supported on this platform")
+
+func terminalColumns() int {
+	return 0
+}
+
+func terminalSize() (int, int, error) {
+	return 0, 0, errNoTerminal
+}
+
+func makeRaw() (func(), error) {
+	return nil, errNoTerminal
+}
+
+func notifyResize(c chan<- os.Signal) {}
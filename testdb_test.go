@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/config"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+// testDBURL names a postgres database the tests may create schemas in. Tests
+// that need a database are skipped when it isn't set.
+const testDBURL = "GATOR_TEST_DB_URL"
+
+// newTestState returns a state backed by a fresh schema holding every
+// migration in sql/schema. The schema is dropped when the test ends.
+func newTestState(t *testing.T) *state {
+	t.Helper()
+
+	dbURL := os.Getenv(testDBURL)
+	if dbURL == "" {
+		t.Skipf("%s is not set", testDBURL)
+	}
+
+	raw := make([]byte, 6)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	schema := "gator_test_" + hex.EncodeToString(raw)
+
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	defer admin.Close()
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("unable to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		cleanup, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			t.Errorf("unable to open database: %v", err)
+			return
+		}
+		defer cleanup.Close()
+		if _, err := cleanup.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("unable to drop schema: %v", err)
+		}
+	})
+
+	//Every pooled connection has to see the test schema, so it goes in the url
+	conn, err := sql.Open("postgres", withSearchPath(t, dbURL, schema))
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrate(t, conn)
+
+	return &state{
+		db:     database.New(conn),
+		conn:   conn,
+		cfg:    &config.Config{},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func withSearchPath(t *testing.T, dbURL, schema string) string {
+	t.Helper()
+
+	if !strings.Contains(dbURL, "://") {
+		return dbURL + " search_path=" + schema
+	}
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		t.Fatalf("unable to parse %s: %v", testDBURL, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// migrate runs the goose Up section of every migration in order.
+func migrate(t *testing.T, conn *sql.DB) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, up, _ := strings.Cut(string(migration), "-- +goose Up")
+		up, _, _ = strings.Cut(up, "-- +goose Down")
+		if _, err := conn.Exec(up); err != nil {
+			t.Fatalf("unable to run %s: %v", file, err)
+		}
+	}
+}
+
+func createTestUser(t *testing.T, s *state, name string) database.User {
+	t.Helper()
+
+	now := time.Now()
+	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      name,
+	})
+	if err != nil {
+		t.Fatalf("unable to create user: %v", err)
+	}
+	return user
+}
+
+// createTestFeed adds a feed owned by user and has user follow it.
+func createTestFeed(t *testing.T, s *state, user database.User, name, feedURL string) database.Feed {
+	t.Helper()
+
+	now := time.Now()
+	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      name,
+		Url:       feedURL,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("unable to create feed: %v", err)
+	}
+	if _, err := followFeed(context.Background(), s, user, feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func createTestPost(t *testing.T, s *state, feed database.Feed, postURL string, createdAt time.Time) database.Post {
+	t.Helper()
+
+	post, err := s.db.CreatePost(context.Background(), database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+		Title:       sql.NullString{String: "Post at " + postURL, Valid: true},
+		Url:         postURL,
+		Description: sql.NullString{String: "<p>Hello</p>", Valid: true},
+		PublishedAt: sql.NullTime{Time: createdAt, Valid: true},
+		FeedID:      feed.ID,
+	})
+	if err != nil {
+		t.Fatalf("unable to create post: %v", err)
+	}
+	return post
+}
@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+	"github.com/kbm-ky/gator/internal/termtext"
+)
+
+const (
+	// tuiPostLimit is how many posts the post list holds.
+	tuiPostLimit = 200
+	// tuiRefreshEvery is how often the user's due feeds are fetched in the
+	// background.
+	tuiRefreshEvery = 5 * time.Minute
+)
+
+const tuiHelp = "tab switch pane · j/k move · enter open · m read · s star · f follow · u unfollow · r refresh · q quit"
+
+type tuiPane int
+
+const (
+	feedPane tuiPane = iota
+	postPane
+	readPane
+)
+
+// tuiPrompt is a question asked on the bottom line. onDone gets the answer,
+// or is never called if the prompt is cancelled.
+type tuiPrompt struct {
+	label  string
+	input  string
+	onDone func(answer string)
+}
+
+type tui struct {
+	s    *state
+	user database.User
+	out  *bufio.Writer
+
+	width  int
+	height int
+	focus  tuiPane
+
+	feeds []database.GetFeedListForUserRow
+	//0 is every feed, i+1 is feeds[i]
+	feedIndex int
+	feedTop   int
+
+	posts     []database.GetPostListForUserRow
+	postIndex int
+	postTop   int
+
+	reading []string
+	readTop int
+
+	status     string
+	prompt     *tuiPrompt
+	refreshing bool
+	refreshed  chan string
+	quit       bool
+}
+
+func handlerTUI(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 0 {
+		return fmt.Errorf("tui expects no arguments")
+	}
+
+	width, height, err := terminalSize()
+	if err != nil {
+		return fmt.Errorf("tui needs a terminal: %w", err)
+	}
+
+	//Log lines would scribble over the screen, unless stderr goes elsewhere
+	quiet := *s
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		quiet.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	t := &tui{
+		s:         &quiet,
+		user:      user,
+		out:       bufio.NewWriter(os.Stdout),
+		width:     width,
+		height:    height,
+		refreshed: make(chan string, 1),
+		status:    tuiHelp,
+	}
+	if err := t.loadFeeds(); err != nil {
+		return err
+	}
+	if err := t.loadPosts(); err != nil {
+		return err
+	}
+
+	restore, err := makeRaw()
+	if err != nil {
+		return fmt.Errorf("unable to put terminal in raw mode: %w", err)
+	}
+	defer restore()
+
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		t.out.WriteString("\x1b[?25h\x1b[?1049l")
+		t.out.Flush()
+	}()
+
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+
+	ticker := time.NewTicker(tuiRefreshEvery)
+	defer ticker.Stop()
+
+	t.refresh(false)
+	for !t.quit {
+		t.draw()
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			t.handleKey(key)
+		case <-resized:
+			if width, height, err := terminalSize(); err == nil {
+				t.width, t.height = width, height
+			}
+		case <-ticker.C:
+			t.refresh(false)
+		case status := <-t.refreshed:
+			t.refreshing = false
+			t.status = status
+			t.reload()
+		}
+	}
+
+	return nil
+}
+
+// readKeys turns what the terminal sends into key names until r fails.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+var escapeKeys = map[string]string{
+	"[A":  "up",
+	"[B":  "down",
+	"[C":  "right",
+	"[D":  "left",
+	"OA":  "up",
+	"OB":  "down",
+	"OC":  "right",
+	"OD":  "left",
+	"[H":  "home",
+	"[F":  "end",
+	"OH":  "home",
+	"OF":  "end",
+	"[1~": "home",
+	"[4~": "end",
+	"[5~": "pgup",
+	"[6~": "pgdown",
+	"[Z":  "backtab",
+}
+
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			if len(b) == 1 || (b[1] != '[' && b[1] != 'O') {
+				keys = append(keys, "esc")
+				b = b[1:]
+				continue
+			}
+			//a CSI sequence runs to its first letter or ~
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end < len(b) {
+				end++
+			}
+			if key, ok := escapeKeys[string(b[1:end])]; ok {
+				keys = append(keys, key)
+			}
+			b = b[end:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+			b = b[1:]
+		case c == '\t':
+			keys = append(keys, "tab")
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+func (t *tui) loadFeeds() error {
+	feeds, err := t.s.db.GetFeedListForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return fmt.Errorf("unable to get feeds for user: %w", err)
+	}
+	t.feeds = feeds
+	t.feedIndex = min(t.feedIndex, len(feeds))
+	return nil
+}
+
+func (t *tui) loadPosts() error {
+	args := database.GetPostListForUserParams{
+		UserID: t.user.ID,
+		Limit:  tuiPostLimit,
+	}
+	if t.feedIndex > 0 {
+		args.FeedID = uuid.NullUUID{UUID: t.feeds[t.feedIndex-1].ID, Valid: true}
+	}
+
+	posts, err := t.s.db.GetPostListForUser(context.Background(), args)
+	if err != nil {
+		return fmt.Errorf("unable to get posts for user: %w", err)
+	}
+
+	//Keep the same post selected as the list shifts under it
+	selected := uuid.Nil
+	if t.postIndex < len(t.posts) {
+		selected = t.posts[t.postIndex].ID
+	}
+	t.posts = posts
+	t.postIndex = 0
+	for i, post := range posts {
+		if post.ID == selected {
+			t.postIndex = i
+			break
+		}
+	}
+	return nil
+}
+
+// reload refreshes both lists after something changed underneath them.
+func (t *tui) reload() {
+	selected := uuid.Nil
+	if t.feedIndex > 0 {
+		selected = t.feeds[t.feedIndex-1].ID
+	}
+	if err := t.loadFeeds(); err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.feedIndex = 0
+	for i, feed := range t.feeds {
+		if feed.ID == selected {
+			t.feedIndex = i + 1
+			break
+		}
+	}
+	if err := t.loadPosts(); err != nil {
+		t.status = err.Error()
+	}
+}
+
+// refresh fetches the user's feeds in the background, only those that are
+// due unless force is set.
+func (t *tui) refresh(force bool) {
+	if t.refreshing {
+		return
+	}
+	t.refreshing = true
+
+	go func() {
+		feeds, err := t.s.db.GetFeedsForUser(context.Background(), t.user.ID)
+		if err != nil {
+			t.refreshed <- fmt.Sprintf("unable to get feeds for user: %s", err)
+			return
+		}
+
+		now := time.Now()
+		fetched, failed := 0, 0
+		for _, feed := range feeds {
+			if !force && feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(now) {
+				continue
+			}
+			if err := scrapeFeed(t.s, feed); err != nil {
+				failed++
+				continue
+			}
+			fetched++
+		}
+
+		status := fmt.Sprintf("refreshed %d feeds at %s", fetched, time.Now().Format("15:04"))
+		if failed > 0 {
+			status += fmt.Sprintf(", %d failed", failed)
+		}
+		t.refreshed <- status
+	}()
+}
+
+func (t *tui) handleKey(key string) {
+	if t.prompt != nil {
+		t.handlePromptKey(key)
+		return
+	}
+
+	switch key {
+	case "q", "ctrl-c":
+		t.quit = true
+	case "tab", "right", "l":
+		t.focus = min(t.focus+1, readPane)
+	case "backtab", "left", "h":
+		t.focus = max(t.focus-1, feedPane)
+	case "down", "j":
+		t.move(1)
+	case "up", "k":
+		t.move(-1)
+	case "pgdown", " ":
+		t.move(t.paneHeight())
+	case "pgup":
+		t.move(-t.paneHeight())
+	case "home", "g":
+		t.move(-1 << 30)
+	case "end", "G":
+		t.move(1 << 30)
+	case "enter":
+		t.enter()
+	case "m":
+		t.toggleRead()
+	case "s":
+		t.toggleStarred()
+	case "f":
+		t.prompt = &tuiPrompt{label: "Follow feed url: ", onDone: t.follow}
+	case "u":
+		t.askUnfollow()
+	case "r":
+		t.status = "refreshing…"
+		t.refresh(true)
+	case "?":
+		t.status = tuiHelp
+	}
+}
+
+func (t *tui) handlePromptKey(key string) {
+	switch key {
+	case "esc", "ctrl-c":
+		t.prompt = nil
+		t.status = tuiHelp
+	case "enter":
+		p := t.prompt
+		t.prompt = nil
+		p.onDone(strings.TrimSpace(p.input))
+	case "backspace":
+		if _, size := utf8.DecodeLastRuneInString(t.prompt.input); size > 0 {
+			t.prompt.input = t.prompt.input[:len(t.prompt.input)-size]
+		}
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			t.prompt.input += key
+		}
+	}
+}
+
+// move shifts the focused pane's selection, or scrolls the reading pane.
+func (t *tui) move(delta int) {
+	switch t.focus {
+	case feedPane:
+		index := max(0, min(t.feedIndex+delta, len(t.feeds)))
+		if index != t.feedIndex {
+			t.feedIndex = index
+			t.postIndex = 0
+			if err := t.loadPosts(); err != nil {
+				t.status = err.Error()
+			}
+		}
+	case postPane:
+		t.postIndex = max(0, min(t.postIndex+delta, len(t.posts)-1))
+	case readPane:
+		t.readTop = max(0, min(t.readTop+delta, len(t.reading)-1))
+	}
+}
+
+func (t *tui) enter() {
+	switch t.focus {
+	case feedPane:
+		t.focus = postPane
+	case postPane:
+		t.open()
+	case readPane:
+		t.move(t.readHeight())
+	}
+}
+
+// open shows the selected post in the reading pane and marks it read.
+func (t *tui) open() {
+	if t.postIndex >= len(t.posts) {
+		return
+	}
+	post := t.posts[t.postIndex]
+
+	body := postBody(post.Article, post.Content, post.Description)
+
+	lines := []string{post.Title.String, ""}
+	lines = append(lines, "Feed: "+post.FeedName)
+	if post.Author.Valid {
+		lines = append(lines, "Author: "+post.Author.String)
+	}
+	lines = append(lines, "Date: "+postTime(post.PublishedAt, post.CreatedAt).Format("Mon, 02 Jan 2006 15:04"))
+	lines = append(lines, "Url: "+post.Url, "")
+	lines = append(lines, strings.Split(termtext.Render(body, t.width-t.feedWidth()-2), "\n")...)
+
+	t.reading = lines
+	t.readTop = 0
+	t.focus = readPane
+
+	if !post.Read {
+		t.setRead(true)
+	}
+}
+
+func (t *tui) toggleRead() {
+	if t.focus == feedPane || t.postIndex >= len(t.posts) {
+		return
+	}
+	t.setRead(!t.posts[t.postIndex].Read)
+}
+
+func (t *tui) setRead(read bool) {
+	post := &t.posts[t.postIndex]
+	if err := setPostRead(context.Background(), t.s, t.user.ID, post.ID, read); err != nil {
+		t.status = err.Error()
+		return
+	}
+	post.Read = read
+
+	if err := t.loadFeeds(); err != nil {
+		t.status = err.Error()
+	}
+}
+
+func (t *tui) toggleStarred() {
+	if t.focus == feedPane || t.postIndex >= len(t.posts) {
+		return
+	}
+	post := &t.posts[t.postIndex]
+
+	now := time.Now()
+	args := database.SetPostStarredParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    t.user.ID,
+		PostID:    post.ID,
+		Starred:   !post.Starred,
+	}
+	if err := t.s.db.SetPostStarred(context.Background(), args); err != nil {
+		t.status = fmt.Sprintf("unable to star post: %s", err)
+		return
+	}
+	post.Starred = !post.Starred
+}
+
+func (t *tui) follow(url string) {
+	if url == "" {
+		t.status = tuiHelp
+		return
+	}
+
+	feed, err := t.s.db.GetFeedByUrl(context.Background(), url)
+	if errors.Is(err, sql.ErrNoRows) {
+		t.status = fmt.Sprintf("%s hasn't been added yet, add it with addfeed", url)
+		return
+	}
+	if err != nil {
+		t.status = fmt.Sprintf("unable to get feed by url: %s", err)
+		return
+	}
+
+	if _, err := followFeed(context.Background(), t.s, t.user, feed); err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.status = fmt.Sprintf("following %s", feed.Name)
+	t.reload()
+	t.refresh(false)
+}
+
+func (t *tui) askUnfollow() {
+	if t.focus != feedPane || t.feedIndex == 0 {
+		t.status = "select a feed in the feed list to unfollow it"
+		return
+	}
+	feed := t.feeds[t.feedIndex-1]
+
+	t.prompt = &tuiPrompt{
+		label: fmt.Sprintf("Unfollow %s? (y/n) ", feed.Title),
+		onDone: func(answer string) {
+			if !strings.EqualFold(answer, "y") {
+				t.status = tuiHelp
+				return
+			}
+			args := database.DeleteFeedFollowParams{
+				UserID: t.user.ID,
+				FeedID: feed.ID,
+			}
+			if err := t.s.db.DeleteFeedFollow(context.Background(), args); err != nil {
+				t.status = fmt.Sprintf("unable to delete feed follow: %s", err)
+				return
+			}
+			t.status = fmt.Sprintf("unfollowed %s", feed.Title)
+			t.feedIndex = 0
+			t.reload()
+		},
+	}
+}
+
+func (t *tui) feedWidth() int {
+	return max(16, min(t.width/4, 40))
+}
+
+// postHeight is the number of rows of the post list; the reading pane gets
+// the rest, below a separator.
+func (t *tui) postHeight() int {
+	return max(3, (t.height-2)/3)
+}
+
+func (t *tui) readHeight() int {
+	return max(1, t.height-2-t.postHeight()-1)
+}
+
+func (t *tui) paneHeight() int {
+	switch t.focus {
+	case feedPane:
+		return t.height - 2
+	case postPane:
+		return t.postHeight()
+	default:
+		return t.readHeight()
+	}
+}
+
+func (t *tui) draw() {
+	fw := t.feedWidth()
+	rw := max(1, t.width-fw-1)
+	rows := t.height - 2
+
+	t.feedTop = scrollTo(t.feedIndex, t.feedTop, rows)
+	t.postTop = scrollTo(t.postIndex, t.postTop, t.postHeight())
+
+	feedLines := make([]string, 0, len(t.feeds)+1)
+	feedLines = append(feedLines, fmt.Sprintf("All feeds (%d)", t.totalUnread()))
+	for _, feed := range t.feeds {
+		if feed.Unread > 0 {
+			feedLines = append(feedLines, fmt.Sprintf("%s (%d)", feed.Title, feed.Unread))
+		} else {
+			feedLines = append(feedLines, feed.Title)
+		}
+	}
+
+	postLines := make([]string, 0, len(t.posts))
+	for _, post := range t.posts {
+		marker := "● "
+		if post.Read {
+			marker = "  "
+		}
+		if post.Starred {
+			marker += "★ "
+		}
+		title := post.Title.String
+		if title == "" {
+			title = post.Url
+		}
+		date := postTime(post.PublishedAt, post.CreatedAt).Format("Jan 02")
+		line := fmt.Sprintf("%s%s %s", marker, date, title)
+		if t.feedIndex == 0 {
+			line += " · " + post.FeedName
+		}
+		postLines = append(postLines, line)
+	}
+
+	t.out.WriteString("\x1b[H")
+
+	header := fmt.Sprintf(" gator · %s", t.user.Name)
+	if t.refreshing {
+		header += " · refreshing…"
+	}
+	t.writeRow(1, "\x1b[7m"+fit(header, t.width)+"\x1b[0m")
+
+	for row := 0; row < rows; row++ {
+		var line strings.Builder
+		line.WriteString(t.cell(feedLines, t.feedIndex, t.feedTop+row, fw, t.focus == feedPane))
+		line.WriteString("│")
+
+		switch {
+		case row < t.postHeight():
+			line.WriteString(t.cell(postLines, t.postIndex, t.postTop+row, rw, t.focus == postPane))
+		case row == t.postHeight():
+			line.WriteString(strings.Repeat("─", rw))
+		default:
+			i := t.readTop + row - t.postHeight() - 1
+			text := ""
+			if i < len(t.reading) {
+				text = t.reading[i]
+			}
+			line.WriteString(fit(text, rw))
+		}
+		t.writeRow(row+2, line.String())
+	}
+
+	bottom := t.status
+	if t.prompt != nil {
+		bottom = t.prompt.label + t.prompt.input
+	}
+	t.writeRow(t.height, fit(bottom, t.width))
+
+	t.out.Flush()
+}
+
+// cell is row i of a list pane, highlighted if it is the selection.
+func (t *tui) cell(lines []string, selected, i, width int, focused bool) string {
+	if i >= len(lines) {
+		return strings.Repeat(" ", width)
+	}
+	text := fit(" "+lines[i], width)
+	if i != selected {
+		return text
+	}
+	if focused {
+		return "\x1b[7m" + text + "\x1b[0m"
+	}
+	return "\x1b[1m" + text + "\x1b[0m"
+}
+
+func (t *tui) writeRow(row int, text string) {
+	fmt.Fprintf(t.out, "\x1b[%d;1H%s", row, text)
+}
+
+func (t *tui) totalUnread() int64 {
+	total := int64(0)
+	for _, feed := range t.feeds {
+		total += feed.Unread
+	}
+	return total
+}
+
+// scrollTo returns the first visible row so that selected stays on screen.
+func scrollTo(selected, top, height int) int {
+	if selected < top {
+		return selected
+	}
+	if selected >= top+height {
+		return selected - height + 1
+	}
+	return top
+}
+
+// fit cuts or pads s to exactly width columns. Everything drawn goes
+// through it, so it also strips control characters feeds may carry and
+// keeps the text on one line.
+func fit(s string, width int) string {
+	s = strings.NewReplacer("\n", " ", "\t", " ").Replace(termtext.Clean(s))
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		return string(runes[:max(width-1, 0)]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
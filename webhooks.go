@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const (
+	webhookBatchSize   = 50
+	webhookMaxAttempts = 5
+	webhookTimeout     = 10 * time.Second
+
+	//Deliveries run on their own schedule, and a run stops taking new
+	//deliveries once it has used up webhookRunLimit
+	webhookPollEvery = 30 * time.Second
+	webhookRunLimit  = 2 * time.Minute
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+type webhookPayload struct {
+	Event      string      `json:"event"`
+	DeliveryID string      `json:"delivery_id"`
+	Post       webhookPost `json:"post"`
+	Feed       webhookFeed `json:"feed"`
+}
+
+type webhookPost struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Url         string     `json:"url"`
+	Description string     `json:"description"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+type webhookFeed struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+func handlerWebhooks(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("webhooks expects a sub-command: add, list, remove or log")
+	}
+	sub := command{name: cmd.args[0], args: cmd.args[1:]}
+
+	switch sub.name {
+	case "add":
+		return webhooksAdd(s, sub, user)
+	case "list":
+		return webhooksList(s, user)
+	case "remove":
+		return webhooksRemove(s, sub, user)
+	case "log":
+		return webhooksLog(s, sub, user)
+	default:
+		return fmt.Errorf("unknown webhooks sub-command: %s", sub.name)
+	}
+}
+
+func webhooksAdd(s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 1 || len(cmd.args) > 2 {
+		return fmt.Errorf("webhooks add expects 1 or 2 arguments: url [feed_url]")
+	}
+	url := cmd.args[0]
+
+	feedID := uuid.NullUUID{}
+	if len(cmd.args) == 2 {
+		feed, err := s.db.GetFeedByUrl(context.Background(), cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("unable to get feed by url: %w", err)
+		}
+		feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("unable to generate secret: %w", err)
+	}
+
+	now := time.Now()
+	args := database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Url:       url,
+		FeedID:    feedID,
+		Secret:    hex.EncodeToString(raw),
+	}
+	webhook, err := s.db.CreateWebhook(context.Background(), args)
+	if err != nil {
+		return fmt.Errorf("unable to create webhook: %w", err)
+	}
+
+	fmt.Printf("webhook created:\n")
+	fmt.Printf("ID: %s\n", webhook.ID)
+	fmt.Printf("URL: %s\n", webhook.Url)
+	fmt.Printf("Secret: %s\n", webhook.Secret)
+	fmt.Printf("Payloads are signed with HMAC-SHA256 in the X-Gator-Signature header\n")
+	fmt.Printf("Deliveries are sent while agg or serve is running\n")
+
+	return nil
+}
+
+func webhooksList(s *state, user database.User) error {
+	webhooks, err := s.db.GetWebhooksForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("unable to get webhooks for user: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		fmt.Printf("ID: %s\n", webhook.ID)
+		fmt.Printf("URL: %s\n", webhook.Url)
+		if webhook.FeedUrl.Valid {
+			fmt.Printf("Feed: %s\n", webhook.FeedUrl.String)
+		} else {
+			fmt.Printf("Feed: (all followed feeds)\n")
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func webhooksRemove(s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("webhooks remove expects 1 argument: id")
+	}
+
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse webhook id: %s [%w]", cmd.args[0], err)
+	}
+
+	args := database.DeleteWebhookParams{
+		ID:     id,
+		UserID: user.ID,
+	}
+	if err := s.db.DeleteWebhook(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to delete webhook: %w", err)
+	}
+
+	fmt.Printf("webhook %s removed\n", id)
+
+	return nil
+}
+
+func webhooksLog(s *state, cmd command, user database.User) error {
+	limit := 20
+	if len(cmd.args) > 0 {
+		n, err := strconv.Atoi(cmd.args[0])
+		if err != nil {
+			return fmt.Errorf("unable to parse limit: %s [%w]", cmd.args[0], err)
+		}
+		limit = n
+	}
+
+	args := database.GetWebhookDeliveriesForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	}
+	deliveries, err := s.db.GetWebhookDeliveriesForUser(context.Background(), args)
+	if err != nil {
+		return fmt.Errorf("unable to get webhook deliveries: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		fmt.Printf("%s  %-9s attempts=%d  %s -> %s\n",
+			delivery.UpdatedAt.Format(time.DateTime), delivery.Status, delivery.Attempts,
+			delivery.PostUrl, delivery.WebhookUrl)
+		if delivery.LastError.Valid {
+			fmt.Printf("    last error: %s\n", delivery.LastError.String)
+		}
+	}
+
+	return nil
+}
+
+// enqueueWebhooks queues a delivery of a newly inserted post to every
+// webhook whose owner follows the post's feed.
+func enqueueWebhooks(s *state, post database.Post) error {
+	args := database.EnqueueWebhookDeliveriesParams{
+		Now:    time.Now(),
+		PostID: post.ID,
+		FeedID: post.FeedID,
+	}
+	if err := s.db.EnqueueWebhookDeliveries(context.Background(), args); err != nil {
+		return fmt.Errorf("unable to enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// runWebhookWorker delivers webhooks until the process exits. It runs apart
+// from scraping so a slow endpoint only holds up other deliveries.
+func runWebhookWorker(s *state, client *http.Client) {
+	ticker := time.NewTicker(webhookPollEvery)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookRunLimit)
+		if err := deliverWebhooks(ctx, s, client); err != nil {
+			s.logger.Error("unable to deliver webhooks", "error", err)
+		}
+		cancel()
+	}
+}
+
+// deliverWebhooks sends every delivery that is due, rescheduling failures
+// with exponential backoff until webhookMaxAttempts is reached. Deliveries
+// are claimed until the run could have finished with them, so agg and serve
+// running at once don't send the same one twice. Once ctx is done the
+// remaining deliveries are left until their claim runs out.
+func deliverWebhooks(ctx context.Context, s *state, client *http.Client) error {
+	start := time.Now()
+	args := database.ClaimDueWebhookDeliveriesParams{
+		ClaimUntil: start.Add(webhookRunLimit + webhookTimeout),
+		Now:        start,
+		BatchSize:  webhookBatchSize,
+	}
+	deliveries, err := s.db.ClaimDueWebhookDeliveries(ctx, args)
+	if err != nil {
+		return fmt.Errorf("unable to claim due webhook deliveries: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		logger := s.logger.With("delivery_id", delivery.ID, "webhook_url", delivery.WebhookUrl, "post_url", delivery.PostUrl)
+
+		if ctx.Err() != nil {
+			s.logger.Warn("out of time for webhook deliveries, leaving the rest for a later run")
+			break
+		}
+
+		statusCode, sendErr := sendWebhook(ctx, client, delivery)
+		if sendErr != nil && ctx.Err() != nil {
+			//Cut off by the run limit rather than the endpoint, so it doesn't count
+			logger.Warn("webhook delivery interrupted, will retry")
+			break
+		}
+
+		now := time.Now()
+		attempts := delivery.Attempts + 1
+		update := database.UpdateWebhookDeliveryParams{
+			ID:            delivery.ID,
+			UpdatedAt:     now,
+			Status:        "delivered",
+			Attempts:      attempts,
+			NextAttemptAt: delivery.NextAttemptAt,
+		}
+		if statusCode != 0 {
+			update.ResponseStatus = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+		}
+
+		if sendErr == nil {
+			update.DeliveredAt = sql.NullTime{Time: now, Valid: true}
+			logger.Debug("webhook delivered")
+		} else {
+			update.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+			if attempts >= webhookMaxAttempts {
+				update.Status = "failed"
+				logger.Error("webhook delivery failed, giving up", "attempts", attempts, "error", sendErr)
+			} else {
+				update.Status = "pending"
+				update.NextAttemptAt = now.Add(time.Minute << (attempts - 1))
+				logger.Warn("webhook delivery failed, will retry", "attempts", attempts, "error", sendErr)
+			}
+		}
+
+		if err := s.db.UpdateWebhookDelivery(context.Background(), update); err != nil {
+			return fmt.Errorf("unable to update webhook delivery: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sendWebhook posts a single signed payload, returning the response status
+// when one was received.
+func sendWebhook(ctx context.Context, client *http.Client, delivery database.ClaimDueWebhookDeliveriesRow) (int, error) {
+	payload := webhookPayload{
+		Event:      "post.created",
+		DeliveryID: delivery.ID.String(),
+		Post: webhookPost{
+			ID:          delivery.PostID.String(),
+			Title:       delivery.PostTitle.String,
+			Url:         delivery.PostUrl,
+			Description: delivery.PostDescription.String,
+		},
+		Feed: webhookFeed{
+			ID:   delivery.FeedID.String(),
+			Name: delivery.FeedName,
+			Url:  delivery.FeedUrl,
+		},
+	}
+	if delivery.PostPublishedAt.Valid {
+		payload.Post.PublishedAt = &delivery.PostPublishedAt.Time
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("unable to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.WebhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("unable to make new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("X-Gator-Event", payload.Event)
+	req.Header.Set("X-Gator-Delivery", payload.DeliveryID)
+	req.Header.Set("X-Gator-Signature", "sha256="+signWebhook(delivery.WebhookSecret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
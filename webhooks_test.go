@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+// webhookReceiver counts the deliveries it gets, answering each with the
+// next status in statuses (and the last one from then on). Every request
+// must carry a valid signature for secret.
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	statuses []int
+	hits     atomic.Int32
+	payloads chan webhookPayload
+}
+
+func newWebhookReceiver(t *testing.T, secret string, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	r := &webhookReceiver{t: t, secret: secret, statuses: statuses, payloads: make(chan webhookPayload, 16)}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	hit := int(r.hits.Add(1))
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Errorf("unable to read delivery: %v", err)
+	}
+	want := "sha256=" + signWebhook(r.secret, body)
+	if got := req.Header.Get("X-Gator-Signature"); !hmac.Equal([]byte(got), []byte(want)) {
+		r.t.Errorf("X-Gator-Signature = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		r.t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.Header.Get("X-Gator-Event"); got != "post.created" {
+		r.t.Errorf("X-Gator-Event = %q", got)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Errorf("unable to decode delivery: %v", err)
+	}
+	if got := req.Header.Get("X-Gator-Delivery"); got != payload.DeliveryID {
+		r.t.Errorf("X-Gator-Delivery = %q, payload has %q", got, payload.DeliveryID)
+	}
+	r.payloads <- payload
+
+	w.WriteHeader(r.statuses[min(hit, len(r.statuses))-1])
+}
+
+func TestSendWebhook(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, "s3cret", http.StatusNoContent)
+	published := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	delivery := database.ClaimDueWebhookDeliveriesRow{
+		ID:              uuid.New(),
+		WebhookUrl:      server.URL,
+		WebhookSecret:   "s3cret",
+		PostID:          uuid.New(),
+		PostUrl:         "https://gators.example/1",
+		PostPublishedAt: sql.NullTime{Time: published, Valid: true},
+		FeedID:          uuid.New(),
+		FeedName:        "Gators",
+		FeedUrl:         "https://gators.example/feed",
+	}
+
+	status, err := sendWebhook(context.Background(), server.Client(), delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("sendWebhook = %d, %v", status, err)
+	}
+
+	payload := <-receiver.payloads
+	if payload.DeliveryID != delivery.ID.String() || payload.Post.Url != delivery.PostUrl || payload.Feed.Name != "Gators" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+	if payload.Post.PublishedAt == nil || !payload.Post.PublishedAt.Equal(published) {
+		t.Errorf("published_at = %v, want %s", payload.Post.PublishedAt, published)
+	}
+}
+
+func TestSendWebhookStatus(t *testing.T) {
+	_, server := newWebhookReceiver(t, "s3cret", http.StatusBadGateway)
+	delivery := database.ClaimDueWebhookDeliveriesRow{
+		ID:            uuid.New(),
+		WebhookUrl:    server.URL,
+		WebhookSecret: "s3cret",
+	}
+
+	status, err := sendWebhook(context.Background(), server.Client(), delivery)
+	if status != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", status, http.StatusBadGateway)
+	}
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("err = %v, want the unexpected status", err)
+	}
+}
+
+// webhookFixture is one post queued for delivery to a webhook at url.
+func webhookFixture(t *testing.T, url string) (*state, database.User, database.Webhook) {
+	t.Helper()
+	s := newTestState(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", "https://gators.example/feed")
+
+	now := time.Now()
+	webhook, err := s.db.CreateWebhook(ctx, database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Url:       url,
+		Secret:    "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := createTestPost(t, s, feed, "https://gators.example/1", now)
+	if err := enqueueWebhooks(s, post); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, user, webhook
+}
+
+func webhookLog(t *testing.T, s *state, user database.User) database.GetWebhookDeliveriesForUserRow {
+	t.Helper()
+	deliveries, err := s.db.GetWebhookDeliveriesForUser(context.Background(), database.GetWebhookDeliveriesForUserParams{
+		UserID: user.ID,
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+// makeDue pulls every pending delivery's next attempt into the past, as if
+// its backoff had run out.
+func makeDue(t *testing.T, s *state) {
+	t.Helper()
+	_, err := s.conn.Exec("UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE status = 'pending'", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeliverWebhooksRetries(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, "s3cret", http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK)
+	s, user, _ := webhookFixture(t, server.URL)
+	ctx := context.Background()
+
+	for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+			t.Fatal(err)
+		}
+		delivery := webhookLog(t, s, user)
+		if delivery.Status != "pending" || delivery.Attempts != int32(attempt+1) {
+			t.Fatalf("after attempt %d: status %s, attempts %d", attempt+1, delivery.Status, delivery.Attempts)
+		}
+		if !delivery.ResponseStatus.Valid || delivery.ResponseStatus.Int32 < 500 {
+			t.Errorf("after attempt %d: response status %v", attempt+1, delivery.ResponseStatus)
+		}
+		if !delivery.LastError.Valid {
+			t.Errorf("after attempt %d: no last error", attempt+1)
+		}
+		if got := delivery.NextAttemptAt.Sub(delivery.UpdatedAt); got != backoff {
+			t.Errorf("after attempt %d: backoff %s, want %s", attempt+1, got, backoff)
+		}
+
+		//Not due yet, so nothing is sent
+		if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+			t.Fatal(err)
+		}
+		if got := receiver.hits.Load(); got != int32(attempt+1) {
+			t.Fatalf("receiver saw %d deliveries, want %d", got, attempt+1)
+		}
+		makeDue(t, s)
+	}
+
+	if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+		t.Fatal(err)
+	}
+	delivery := webhookLog(t, s, user)
+	if delivery.Status != "delivered" || delivery.Attempts != 3 || !delivery.DeliveredAt.Valid {
+		t.Errorf("status %s, attempts %d, delivered at %v", delivery.Status, delivery.Attempts, delivery.DeliveredAt)
+	}
+	if delivery.ResponseStatus.Int32 != http.StatusOK {
+		t.Errorf("response status %d, want %d", delivery.ResponseStatus.Int32, http.StatusOK)
+	}
+}
+
+func TestDeliverWebhooksGivesUp(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, "s3cret", http.StatusInternalServerError)
+	s, user, _ := webhookFixture(t, server.URL)
+	ctx := context.Background()
+
+	for range webhookMaxAttempts + 1 {
+		if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+			t.Fatal(err)
+		}
+		makeDue(t, s)
+	}
+
+	if got := receiver.hits.Load(); got != webhookMaxAttempts {
+		t.Errorf("receiver saw %d deliveries, want %d", got, webhookMaxAttempts)
+	}
+	delivery := webhookLog(t, s, user)
+	if delivery.Status != "failed" || delivery.Attempts != webhookMaxAttempts {
+		t.Errorf("status %s, attempts %d", delivery.Status, delivery.Attempts)
+	}
+	if delivery.ResponseStatus.Int32 != http.StatusInternalServerError || !delivery.LastError.Valid {
+		t.Errorf("response status %d, last error %v", delivery.ResponseStatus.Int32, delivery.LastError)
+	}
+	if delivery.DeliveredAt.Valid {
+		t.Error("a failed delivery has a delivered_at")
+	}
+}
+
+func TestDeliverWebhooksRunLimit(t *testing.T) {
+	//An endpoint that never answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	s, user, _ := webhookFixture(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > webhookTimeout/2 {
+		t.Errorf("the run took %s despite its limit", elapsed)
+	}
+
+	//Being cut off isn't the endpoint's fault, so the attempt doesn't count
+	delivery := webhookLog(t, s, user)
+	if delivery.Status != "pending" || delivery.Attempts != 0 {
+		t.Errorf("status %s, attempts %d, want an untouched delivery", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestDeliverWebhooksClaimed(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, "s3cret", http.StatusOK)
+	s, user, _ := webhookFixture(t, server.URL)
+	ctx := context.Background()
+
+	//Another process, say agg next to serve, has just taken the delivery
+	now := time.Now()
+	claimed, err := s.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		ClaimUntil: now.Add(time.Minute),
+		Now:        now,
+		BatchSize:  webhookBatchSize,
+	})
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claimed %d deliveries, %v", len(claimed), err)
+	}
+
+	if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiver.hits.Load(); got != 0 {
+		t.Fatalf("receiver saw %d deliveries of a claimed one", got)
+	}
+
+	//Once the claim runs out it is sent after all
+	makeDue(t, s)
+	if err := deliverWebhooks(ctx, s, server.Client()); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiver.hits.Load(); got != 1 {
+		t.Errorf("receiver saw %d deliveries, want 1", got)
+	}
+	if delivery := webhookLog(t, s, user); delivery.Status != "delivered" {
+		t.Errorf("status %s, want delivered", delivery.Status)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+const (
+	websubLeaseSeconds = 10 * 24 * 60 * 60
+	// websubRenewWindow is how long before a lease runs out it is renewed.
+	websubRenewWindow = 24 * time.Hour
+	// websubRetryAfter is how long to wait on a hub that hasn't verified or
+	// has denied a subscription before asking again.
+	websubRetryAfter    = time.Hour
+	websubMaintainEvery = 10 * time.Minute
+	// websubQueueSize is how many pushes can wait to be saved before hubs
+	// are told to come back later.
+	websubQueueSize = 64
+)
+
+var websubSignatureHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// webSubPush is content a hub pushed, waiting to be saved.
+type webSubPush struct {
+	sub         database.WebsubSubscription
+	body        []byte
+	contentType string
+}
+
+// registerWebSubRoutes serves the callback hubs verify subscriptions
+// against and push new content to. Pushed content is saved by a worker so
+// hubs aren't kept waiting on it.
+func registerWebSubRoutes(s *state, mux *http.ServeMux) {
+	pushes := make(chan webSubPush, websubQueueSize)
+	go saveWebSubPushes(s, pushes)
+
+	mux.HandleFunc("GET /websub/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSubVerify(s, w, r)
+	})
+	mux.HandleFunc("POST /websub/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSubContent(s, pushes, w, r)
+	})
+}
+
+func webSubSubscription(s *state, r *http.Request) (database.WebsubSubscription, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return database.WebsubSubscription{}, err
+	}
+	return s.db.GetWebSubSubscription(r.Context(), id)
+}
+
+// handleWebSubVerify answers the hub's intent verification, echoing the
+// challenge only for a subscription we actually asked for.
+func handleWebSubVerify(s *state, w http.ResponseWriter, r *http.Request) {
+	sub, err := webSubSubscription(s, r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	logger := s.logger.With("subscription_id", sub.ID, "hub_url", sub.HubUrl)
+
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+	now := time.Now()
+
+	if mode == "denied" {
+		logger.Warn("websub subscription denied", "reason", query.Get("hub.reason"))
+		args := database.SetWebSubSubscriptionStatusParams{
+			ID:        sub.ID,
+			Status:    "denied",
+			UpdatedAt: now,
+		}
+		if err := s.db.SetWebSubSubscriptionStatus(r.Context(), args); err != nil {
+			logger.Error("unable to update websub subscription", "error", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if mode != "subscribe" || query.Get("hub.topic") != sub.TopicUrl || query.Get("hub.challenge") == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	lease := websubLeaseSeconds
+	if n, err := strconv.Atoi(query.Get("hub.lease_seconds")); err == nil && n > 0 {
+		lease = n
+	}
+
+	args := database.ActivateWebSubSubscriptionParams{
+		ID:             sub.ID,
+		LeaseExpiresAt: sql.NullTime{Time: now.Add(time.Duration(lease) * time.Second), Valid: true},
+		UpdatedAt:      now,
+	}
+	if err := s.db.ActivateWebSubSubscription(r.Context(), args); err != nil {
+		logger.Error("unable to activate websub subscription", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("websub subscription verified", "lease_seconds", lease)
+
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, query.Get("hub.challenge"))
+}
+
+// handleWebSubContent queues a pushed feed and acknowledges it straight
+// away. Content with a missing or bad signature is acknowledged but
+// ignored, as the spec asks.
+func handleWebSubContent(s *state, pushes chan<- webSubPush, w http.ResponseWriter, r *http.Request) {
+	sub, err := webSubSubscription(s, r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	logger := s.logger.With("subscription_id", sub.ID, "feed_id", sub.FeedID)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFeedBytes(s)+1))
+	if err != nil {
+		http.Error(w, "unable to read body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > maxFeedBytes(s) {
+		http.Error(w, errFeedTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !validWebSubSignature(sub.Secret, r.Header.Get("X-Hub-Signature"), body) {
+		logger.Warn("ignoring websub content with invalid signature")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	select {
+	case pushes <- webSubPush{sub: sub, body: body, contentType: r.Header.Get("Content-Type")}:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		//Hubs retry failed pushes, so asking for a later one loses nothing
+		logger.Warn("websub queue is full, asking the hub to retry")
+		w.Header().Set("Retry-After", strconv.Itoa(int(websubMaintainEvery/time.Second)))
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}
+}
+
+// saveWebSubPushes saves queued pushes until pushes is closed.
+func saveWebSubPushes(s *state, pushes <-chan webSubPush) {
+	for push := range pushes {
+		saveWebSubPush(context.Background(), s, push)
+	}
+}
+
+// saveWebSubPush saves the items of a pushed RSS or Atom feed as posts.
+func saveWebSubPush(ctx context.Context, s *state, push webSubPush) {
+	logger := s.logger.With("subscription_id", push.sub.ID, "feed_id", push.sub.FeedID)
+
+	feed, err := s.db.GetFeed(ctx, push.sub.FeedID)
+	if err != nil {
+		logger.Error("unable to get feed", "error", err)
+		return
+	}
+
+	stream, err := newFeedStream(bytes.NewReader(push.body), push.contentType, maxFeedBytes(s))
+	if err != nil {
+		logger.Error("unable to read pushed feed", "error", err)
+		return
+	}
+
+	count := 0
+	for item, err := range stream.Items() {
+		if err != nil {
+			logger.Error("unable to parse pushed feed", "error", err)
+			break
+		}
+		savePost(s, logger, feed, item)
+		count++
+	}
+	logger.Debug("websub content saved", "items", count)
+}
+
+// validWebSubSignature checks an X-Hub-Signature header of the form
+// "method=hexdigest" against an HMAC of body.
+func validWebSubSignature(secret, header string, body []byte) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+	newHash, ok := websubSignatureHashes[strings.ToLower(method)]
+	if !ok {
+		return false
+	}
+	want, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// maintainWebSub keeps subscriptions to every feed with a hub alive until
+// ctx is done.
+func maintainWebSub(ctx context.Context, s *state, publicURL string) {
+	ticker := time.NewTicker(websubMaintainEvery)
+	defer ticker.Stop()
+
+	for {
+		if err := subscribeWebSub(ctx, s, publicURL); err != nil {
+			s.logger.Error("unable to maintain websub subscriptions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// subscribeWebSub asks hubs to (re)subscribe every feed that has no
+// subscription yet, whose lease is about to run out, or whose last attempt
+// went nowhere.
+func subscribeWebSub(ctx context.Context, s *state, publicURL string) error {
+	now := time.Now()
+	args := database.GetFeedsNeedingWebSubParams{
+		RenewBefore: now.Add(websubRenewWindow),
+		RetryBefore: now.Add(-websubRetryAfter),
+	}
+	feeds, err := s.db.GetFeedsNeedingWebSub(ctx, args)
+	if err != nil {
+		return fmt.Errorf("unable to get feeds needing websub: %w", err)
+	}
+
+	for _, feed := range feeds {
+		logger := s.logger.With("feed_id", feed.FeedID, "hub_url", feed.HubUrl)
+
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return fmt.Errorf("unable to generate secret: %w", err)
+		}
+
+		upsert := database.UpsertWebSubSubscriptionParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			FeedID:    feed.FeedID,
+			HubUrl:    feed.HubUrl,
+			TopicUrl:  feed.TopicUrl,
+			Secret:    hex.EncodeToString(raw),
+		}
+		sub, err := s.db.UpsertWebSubSubscription(ctx, upsert)
+		if err != nil {
+			return fmt.Errorf("unable to save websub subscription: %w", err)
+		}
+
+		callback := strings.TrimSuffix(publicURL, "/") + "/websub/" + sub.ID.String()
+		if err := requestWebSub(ctx, sub, callback); err != nil {
+			logger.Warn("websub subscription request failed", "error", err)
+			continue
+		}
+		logger.Info("websub subscription requested", "topic_url", sub.TopicUrl)
+	}
+
+	return nil
+}
+
+func requestWebSub(ctx context.Context, sub database.WebsubSubscription, callback string) error {
+	form := url.Values{}
+	form.Set("hub.mode", "subscribe")
+	form.Set("hub.topic", sub.TopicUrl)
+	form.Set("hub.callback", callback)
+	form.Set("hub.secret", sub.Secret)
+	form.Set("hub.lease_seconds", strconv.Itoa(websubLeaseSeconds))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.HubUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("unable to make new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "gator")
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// recordFeedHub remembers the hub a feed advertises so serve can subscribe
+// to it.
+func recordFeedHub(ctx context.Context, s *state, feed database.Feed, channel RSSChannel) error {
+	hub := sql.NullString{String: channel.Hub, Valid: channel.Hub != ""}
+	self := sql.NullString{String: channel.Self, Valid: channel.Self != ""}
+	if hub == feed.HubUrl && self == feed.SelfUrl {
+		return nil
+	}
+
+	args := database.SetFeedHubParams{
+		ID:        feed.ID,
+		HubUrl:    hub,
+		SelfUrl:   self,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.SetFeedHub(ctx, args); err != nil {
+		return fmt.Errorf("unable to set feed hub: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kbm-ky/gator/internal/database"
+)
+
+func TestValidWebSubSignature(t *testing.T) {
+	body := []byte("<feed/>")
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sha256Sig := hex.EncodeToString(mac.Sum(nil))
+	mac = hmac.New(sha1.New, []byte("s3cret"))
+	mac.Write(body)
+	sha1Sig := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"sha256=" + sha256Sig, true},
+		{"SHA256=" + sha256Sig, true},
+		{"sha1=" + sha1Sig, true},
+		{"sha256=" + sha1Sig, false},
+		{"md5=" + sha256Sig, false},
+		{"sha256=zz", false},
+		{sha256Sig, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validWebSubSignature("s3cret", tt.header, body); got != tt.want {
+			t.Errorf("validWebSubSignature(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// webSubFixture is alice following a feed with an active subscription.
+func webSubFixture(t *testing.T) (*state, database.User, database.WebsubSubscription) {
+	t.Helper()
+	s := newTestState(t)
+
+	user := createTestUser(t, s, "alice")
+	feed := createTestFeed(t, s, user, "Gators", "https://gators.example/atom.xml")
+
+	now := time.Now()
+	sub, err := s.db.UpsertWebSubSubscription(context.Background(), database.UpsertWebSubSubscriptionParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		FeedID:    feed.ID,
+		HubUrl:    "https://hub.example/",
+		TopicUrl:  feed.Url,
+		Secret:    "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, user, sub
+}
+
+func pushWebSub(t *testing.T, s *state, pushes chan webSubPush, sub database.WebsubSubscription, body []byte, signature string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/websub/"+sub.ID.String(), strings.NewReader(string(body)))
+	req.SetPathValue("id", sub.ID.String())
+	req.Header.Set("Content-Type", "application/atom+xml")
+	req.Header.Set("X-Hub-Signature", signature)
+	rec := httptest.NewRecorder()
+	handleWebSubContent(s, pushes, rec, req)
+	return rec
+}
+
+func TestWebSubContent(t *testing.T) {
+	s, user, sub := webSubFixture(t)
+	body, err := os.ReadFile(filepath.Join("testdata", "atom.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(sub.Secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	pushes := make(chan webSubPush, 1)
+
+	//A bad signature is acknowledged and dropped
+	if rec := pushWebSub(t, s, pushes, sub, body, "sha256=00"); rec.Code != http.StatusAccepted {
+		t.Errorf("bad signature: status %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if len(pushes) != 0 {
+		t.Fatal("content with a bad signature was queued")
+	}
+
+	//Nothing is saved before the hub has its answer
+	if rec := pushWebSub(t, s, pushes, sub, body, signature); rec.Code != http.StatusAccepted {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if _, err := s.db.GetPostForUserByUrl(context.Background(), database.GetPostForUserByUrlParams{UserID: user.ID, Url: "https://gators.example/crocs"}); err == nil {
+		t.Error("the post was saved before the push was acknowledged")
+	}
+
+	//With the queue full the hub is told to retry
+	if rec := pushWebSub(t, s, pushes, sub, body, signature); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("full queue: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	saveWebSubPush(context.Background(), s, <-pushes)
+	for _, url := range []string{"https://gators.example/crocs", "https://gators.example/updated"} {
+		post, err := s.db.GetPostForUserByUrl(context.Background(), database.GetPostForUserByUrlParams{UserID: user.ID, Url: url})
+		if err != nil {
+			t.Errorf("pushed entry %s wasn't saved: %v", url, err)
+			continue
+		}
+		if post.FeedID != sub.FeedID {
+			t.Errorf("%s saved to feed %s, want %s", url, post.FeedID, sub.FeedID)
+		}
+	}
+}
+
+func TestWebSubContentUnknownSubscription(t *testing.T) {
+	s, _, _ := webSubFixture(t)
+	sub := database.WebsubSubscription{ID: uuid.New()}
+	if rec := pushWebSub(t, s, make(chan webSubPush, 1), sub, []byte("<feed/>"), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}